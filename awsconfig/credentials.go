@@ -0,0 +1,28 @@
+package awsconfig
+
+import (
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+)
+
+// ReadCredentials returns credentials from the AWS SDK default chain: AWS_*
+// env vars, shared config and credentials files profile set by AWS_PROFILE,
+// web identity token (EKS IRSA), then ECS task or EC2 instance roles
+func ReadCredentials() (*credentials.Credentials, error) {
+
+	// Load shared config too, it may define role or web identity profiles
+	sess, err := session.NewSessionWithOptions(session.Options{SharedConfigState: session.SharedConfigEnable})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load AWS config: %w", err)
+	}
+
+	// Resolve credentials now to report missing ones before signing
+	_, err = sess.Config.Credentials.Get()
+	if err != nil {
+		return nil, fmt.Errorf("no AWS credentials found: %w", err)
+	}
+
+	return sess.Config.Credentials, nil
+}
@@ -0,0 +1,91 @@
+package awsconfig
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReadCredentials(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "aws")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	credentialsFile := filepath.Join(dir, "credentials")
+	err = ioutil.WriteFile(credentialsFile, []byte("[ci]\naws_access_key_id = AKIDFILE\naws_secret_access_key = secret\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		env     map[string]string
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "env vars",
+			env:  map[string]string{"AWS_ACCESS_KEY_ID": "AKIDENV", "AWS_SECRET_ACCESS_KEY": "secret", "AWS_PROFILE": "ci"},
+			want: "AKIDENV",
+		},
+		{
+			name: "credentials file profile",
+			env:  map[string]string{"AWS_PROFILE": "ci"},
+			want: "AKIDFILE",
+		},
+		{
+			name:    "unknown profile",
+			env:     map[string]string{"AWS_PROFILE": "prod"},
+			wantErr: true,
+		},
+		{
+			name:    "no credentials",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			// Isolate from the job config, instance metadata lookups included
+			env := map[string]string{
+				"AWS_SHARED_CREDENTIALS_FILE": credentialsFile,
+				"AWS_CONFIG_FILE":             filepath.Join(dir, "config"),
+				"AWS_EC2_METADATA_DISABLED":   "true",
+				"AWS_REGION":                  "eu-west-1",
+			}
+			for name, value := range tt.env {
+				env[name] = value
+			}
+			for _, name := range []string{
+				"AWS_ACCESS_KEY_ID", "AWS_SECRET_ACCESS_KEY", "AWS_SESSION_TOKEN", "AWS_PROFILE",
+				"AWS_SHARED_CREDENTIALS_FILE", "AWS_CONFIG_FILE", "AWS_EC2_METADATA_DISABLED", "AWS_REGION",
+				"AWS_WEB_IDENTITY_TOKEN_FILE", "AWS_ROLE_ARN",
+				"AWS_CONTAINER_CREDENTIALS_RELATIVE_URI", "AWS_CONTAINER_CREDENTIALS_FULL_URI",
+			} {
+				os.Setenv(name, env[name])
+				defer os.Unsetenv(name)
+			}
+
+			creds, err := ReadCredentials()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ReadCredentials() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			value, err := creds.Get()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if value.AccessKeyID != tt.want {
+				t.Errorf("ReadCredentials() access key = %q, want %q", value.AccessKeyID, tt.want)
+			}
+		})
+	}
+}
@@ -1,10 +1,20 @@
 package cmd
 
 import (
+	"bufio"
+	"bytes"
 	"errors"
 	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"regexp"
 	"strings"
+	"text/template"
+
+	"github.com/Masterminds/sprig/v3"
+	"github.com/spf13/pflag"
 )
 
 func convertToEnvName(name string) (string, error) {
@@ -45,3 +55,86 @@ func convertToEnvName(name string) (string, error) {
 func ErrorToEval(err error) {
 	fmt.Printf("echo \"echo %s\"\n", err)
 }
+
+// templateEnv returns environment variables, CI variables included, as
+// template data
+func templateEnv() map[string]string {
+
+	env := map[string]string{}
+	for _, kv := range os.Environ() {
+		parts := strings.SplitN(kv, "=", 2)
+		if len(parts) == 2 {
+			env[parts[0]] = parts[1]
+		}
+	}
+
+	return env
+}
+
+// renderTemplate executes a sprig template with environment variables as data
+func renderTemplate(name, text string) (string, error) {
+
+	tmpl, err := template.New(name).Funcs(sprig.TxtFuncMap()).Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("failed to create %s template: %w", name, err)
+	}
+
+	var out bytes.Buffer
+	if err := tmpl.Execute(&out, templateEnv()); err != nil {
+		return "", fmt.Errorf("failed to execute %s template: %w", name, err)
+	}
+
+	return out.String(), nil
+}
+
+// transformFile streams input through transform into output, which is only
+// replaced once transform succeeds
+func transformFile(input, output string, transform func(in io.Reader, out io.Writer) error) error {
+
+	in, err := os.Open(input)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", input, err)
+	}
+	defer in.Close()
+
+	// Write to a temporary file renamed once complete
+	out, err := ioutil.TempFile(filepath.Dir(output), filepath.Base(output)+".*")
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", output, err)
+	}
+	defer os.Remove(out.Name())
+
+	w := bufio.NewWriter(out)
+	err = transform(in, w)
+	if err == nil {
+		err = w.Flush()
+	}
+	if errClose := out.Close(); err == nil {
+		err = errClose
+	}
+	if err != nil {
+		return err
+	}
+
+	err = os.Chmod(out.Name(), 0600)
+	if err != nil {
+		return fmt.Errorf("failed to set %s permissions: %w", output, err)
+	}
+	err = os.Rename(out.Name(), output)
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %w", output, err)
+	}
+
+	return nil
+}
+
+// flagAliases returns a flag name normalization function accepting short
+// aliases of full flag names, e.g. "--role" for "--vault-db-role"
+func flagAliases(aliases map[string]string) func(*pflag.FlagSet, string) pflag.NormalizedName {
+	return func(f *pflag.FlagSet, name string) pflag.NormalizedName {
+		if full, ok := aliases[name]; ok {
+			name = full
+		}
+		return pflag.NormalizedName(name)
+	}
+}
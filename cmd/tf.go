@@ -1,15 +1,137 @@
 package cmd
 
 import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/Ouest-France/gogci/command"
+	"github.com/Ouest-France/gogci/terraform"
+	"github.com/acarl005/stripansi"
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
 )
 
 // tfCmd represents the tf command
 var tfCmd = &cobra.Command{
 	Use:   "tf",
 	Short: "Terraform helpers",
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+
+		for _, flag := range []string{
+			"tool",
+			"binary",
+			"versions-dir",
+			"versions-mirror",
+			"workspace",
+			"workspace-from",
+			"workspace-label-prefix",
+		} {
+
+			// Bind viper to flag
+			err := viper.BindPFlag(flag, cmd.Flags().Lookup(flag))
+			if err != nil {
+				return fmt.Errorf("error binding viper to flag %q: %w", flag, err)
+			}
+		}
+
+		return nil
+	},
+}
+
+// newTool returns the infrastructure as code tool selected by flags, running
+// a binary that satisfies the version required by the root module
+func newTool() (terraform.Tool, error) {
+
+	tool, err := terraform.NewTool(viper.GetString("tool"), viper.GetString("binary"))
+	if err != nil {
+		return nil, err
+	}
+
+	tool, err = terraform.PinVersion(tool, ".", viper.GetString("versions-dir"), viper.GetString("versions-mirror"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to select %s version: %w", viper.GetString("tool"), err)
+	}
+
+	return tool, nil
+}
+
+// workspace returns the terraform workspace set by flag or derived from the
+// target branch or a merge request label, empty when workspaces are not used
+func workspace() (string, error) {
+
+	if ws := viper.GetString("workspace"); ws != "" {
+		return ws, nil
+	}
+
+	switch from := viper.GetString("workspace-from"); from {
+	case "":
+		return "", nil
+
+	case "branch":
+		// Merge request target branch or pipeline branch
+		for _, env := range []string{"CI_MERGE_REQUEST_TARGET_BRANCH_NAME", "CI_COMMIT_BRANCH"} {
+			if branch := os.Getenv(env); branch != "" {
+				return branch, nil
+			}
+		}
+		return "", errors.New("no branch found to derive workspace from")
+
+	case "label":
+		// First merge request label with workspace prefix
+		prefix := viper.GetString("workspace-label-prefix")
+		for _, label := range strings.Split(os.Getenv("CI_MERGE_REQUEST_LABELS"), ",") {
+			if strings.HasPrefix(label, prefix) && len(label) > len(prefix) {
+				return strings.TrimPrefix(label, prefix), nil
+			}
+		}
+		return "", fmt.Errorf("no merge request label with prefix %q found to derive workspace from", prefix)
+
+	default:
+		return "", fmt.Errorf("unknown workspace source %q, must be branch or label", from)
+	}
+}
+
+// selectWorkspace selects the workspace, creating it when it doesn't exist
+// and create is set. Only plan creates workspaces, other commands must not
+// run against a new empty state.
+func selectWorkspace(tool terraform.Tool, ws string, create bool) error {
+
+	if ws == "" {
+		return nil
+	}
+
+	_, stderr, _, err := command.Run(tool.Binary(), tool.Args([]string{"workspace", "select", ws}))
+	if err == nil {
+		return nil
+	}
+	if !create || !workspaceMissing(stderr) {
+		return fmt.Errorf("failed to select workspace %q: %w", ws, err)
+	}
+
+	_, _, _, err = command.Run(tool.Binary(), tool.Args([]string{"workspace", "new", ws}))
+	if err != nil {
+		return fmt.Errorf("failed to create workspace %q: %w", ws, err)
+	}
+
+	return nil
+}
+
+// workspaceMissing returns whether a "workspace select" error output reports
+// that the workspace doesn't exist
+func workspaceMissing(stderr []byte) bool {
+	return strings.Contains(stripansi.Strip(string(stderr)), "doesn't exist")
 }
 
 func init() {
+	tfCmd.PersistentFlags().String("tool", "terraform", "Infrastructure as code tool: terraform, tofu or terragrunt [GOGCI_TOOL]")
+	tfCmd.PersistentFlags().String("binary", "", "Tool binary (default: tool name) [GOGCI_BINARY]")
+	tfCmd.PersistentFlags().String("versions-dir", "", "Directory of installed tool versions as <version>/<binary> [GOGCI_VERSIONS_DIR]")
+	tfCmd.PersistentFlags().String("workspace", "", "Terraform workspace selected or created before plan and apply [GOGCI_WORKSPACE]")
+	tfCmd.PersistentFlags().String("workspace-from", "", "Derive workspace from 'branch' or merge request 'label' [GOGCI_WORKSPACE_FROM]")
+	tfCmd.PersistentFlags().String("workspace-label-prefix", "workspace::", "Prefix of merge request labels naming the workspace [GOGCI_WORKSPACE_LABEL_PREFIX]")
+	tfCmd.PersistentFlags().String("versions-mirror", "", "Releases mirror URL used to install missing versions [GOGCI_VERSIONS_MIRROR]")
+
 	rootCmd.AddCommand(tfCmd)
 }
@@ -6,6 +6,8 @@ import (
 
 	"github.com/Ouest-France/gogci/command"
 	"github.com/Ouest-France/gogci/gitlab"
+	"github.com/Ouest-France/gogci/policy"
+	"github.com/Ouest-France/gogci/terraform"
 	"github.com/acarl005/stripansi"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
@@ -17,6 +19,12 @@ var tfApplyCmd = &cobra.Command{
 	Short: "Launch terraform apply and send output to Gitlab MR comment",
 	PreRunE: func(cmd *cobra.Command, args []string) error {
 
+		// Bind vault client flags
+		err := bindVaultClientFlags(cmd)
+		if err != nil {
+			return err
+		}
+
 		// Bind string flags
 		for _, flag := range []string{"gitlab-url", "gitlab-token"} {
 
@@ -32,8 +40,24 @@ var tfApplyCmd = &cobra.Command{
 			}
 		}
 
-		// Bind bool flags
-		for _, flag := range []string{"approved", "oldest"} {
+		// Bind bool and list flags
+		for _, flag := range []string{
+			"oldest",
+			"commit-status",
+			"policy-dir",
+			"export-outputs",
+			"state-backup-dir",
+		} {
+
+			// Bind viper to flag
+			err := viper.BindPFlag(flag, cmd.Flags().Lookup(flag))
+			if err != nil {
+				return fmt.Errorf("error binding viper to flag %q: %w", flag, err)
+			}
+		}
+
+		// Bind approval and outputs export flags
+		for _, flag := range append(approvalFlags, outputFlags...) {
 
 			// Bind viper to flag
 			err := viper.BindPFlag(flag, cmd.Flags().Lookup(flag))
@@ -44,23 +68,97 @@ var tfApplyCmd = &cobra.Command{
 
 		return nil
 	},
-	RunE: func(cmd *cobra.Command, args []string) error {
+	RunE: func(cmd *cobra.Command, args []string) (err error) {
+
+		// Get infrastructure as code tool
+		tool, err := newTool()
+		if err != nil {
+			return err
+		}
+
+		// Select workspace
+		ws, err := workspace()
+		if err != nil {
+			return err
+		}
+		err = selectWorkspace(tool, ws, false)
+		if err != nil {
+			return err
+		}
 
 		// Create gitlab client
-		gc := gitlab.Client{Token: viper.GetString("gitlab-token"), URL: viper.GetString("gitlab-url")}
+		gc := gitlab.Client{
+			Token:        viper.GetString("gitlab-token"),
+			URL:          viper.GetString("gitlab-url"),
+			Tool:         terraform.Describe(tool),
+			Workspace:    ws,
+			CommitStatus: viper.GetBool("commit-status"),
+		}
+
+		// Fail the apply status left pending or running by errors
+		defer func() {
+			if err != nil {
+				if errGit := gc.FailCommitStatus("apply", "Terraform apply failed"); errGit != nil {
+					err = fmt.Errorf("%w (error setting terraform apply failed status: %s)", err, errGit)
+				}
+			}
+		}()
+
+		// Set apply status as pending until checks pass
+		err = gc.SetCommitStatus("apply", gitlab.StatusPending, "Terraform apply waiting for checks")
+		if err != nil {
+			return fmt.Errorf("error setting terraform apply pending status: %w", err)
+		}
+
+		// Load policies and protected resources
+		rules, err := policy.Load(viper.GetString("policy-dir"))
+		if err != nil {
+			return fmt.Errorf("error loading policies: %w", err)
+		}
+		protected := []policy.Protected{}
+		err = viper.UnmarshalKey("protected-resources", &protected)
+		if err != nil {
+			return fmt.Errorf("error decoding protected resources: %w", err)
+		}
+
+		// Read the saved plan when it must be checked
+		var plan *terraform.Plan
+		if len(rules) > 0 || len(protected) > 0 {
+			planFile := terraform.PlanFileFromApplyArgs(args)
+			if planFile == "" {
+				return fmt.Errorf("policy and protected resources checks require a saved plan file passed to 'terraform apply'")
+			}
+
+			plan, err = terraform.ShowPlan(tool.Binary(), planFile)
+			if err != nil {
+				return fmt.Errorf("error reading terraform plan: %w", err)
+			}
+		}
 
-		// Check merge request approval when approved flag is set
-		if viper.GetBool("approved") {
-			approved, err := gc.CheckMergeRequestApproved()
+		// Check merge request approval when approved flag is set, changes on
+		// protected resources always require their groups approval
+		approvalPolicy := approvalPolicyFromFlags()
+		if plan != nil {
+			approvalPolicy.ProtectedGroups = policy.RequiredGroups(protected, plan)
+		}
+		if !viper.GetBool("approved") {
+			approvalPolicy = approvalPolicy.ProtectedOnly()
+		}
+		if viper.GetBool("approved") || len(approvalPolicy.ProtectedGroups) > 0 {
+			approved, reasons, err := gc.CheckMergeRequestApproved(approvalPolicy)
 			if err != nil {
 				return fmt.Errorf("failed to check merge request approval: %w", err)
 			}
 
 			if !approved {
-				err = gc.TerraformApplyNotApproved()
+				err = gc.TerraformApplyNotApproved(reasons)
 				if err != nil {
 					return fmt.Errorf("failed to send 'terraform apply not approved' comment: %w", err)
 				}
+				err = gc.SetCommitStatus("apply", gitlab.StatusFailed, "Terraform apply not approved")
+				if err != nil {
+					return fmt.Errorf("failed to set 'terraform apply not approved' status: %w", err)
+				}
 
 				return fmt.Errorf("merge request must be approved to execute 'terraform apply'")
 			}
@@ -76,32 +174,95 @@ var tfApplyCmd = &cobra.Command{
 				if err != nil {
 					return fmt.Errorf("failed to send 'terraform apply blocked' comment: %w", err)
 				}
+				err = gc.SetCommitStatus("apply", gitlab.StatusFailed, "Terraform apply blocked by older merge requests")
+				if err != nil {
+					return fmt.Errorf("failed to set 'terraform apply blocked' status: %w", err)
+				}
 
 				return fmt.Errorf("all older merge requests must be closed to launch 'terraform apply'")
 			}
 		}
 
+		// Check policies against the saved plan
+		if len(rules) > 0 {
+			results := policy.Evaluate(rules, plan)
+			if policy.Blocked(results) {
+				err = gc.TerraformApplyPolicyFailed(results)
+				if err != nil {
+					return fmt.Errorf("failed to send 'terraform apply policy failed' comment: %w", err)
+				}
+				err = gc.SetCommitStatus("apply", gitlab.StatusFailed, "Terraform apply blocked by policy checks")
+				if err != nil {
+					return fmt.Errorf("failed to set 'terraform apply policy failed' status: %w", err)
+				}
+
+				return fmt.Errorf("blocking policy checks must pass to execute 'terraform apply'")
+			}
+		}
+
+		// Snapshot state to allow a restore after a bad apply
+		backup := terraform.StateBackup{}
+		if dir := viper.GetString("state-backup-dir"); dir != "" {
+			name := ws
+			if name == "" {
+				name = "default"
+			}
+			backup, err = terraform.BackupState(tool, dir, fmt.Sprintf("%s-%s.tfstate", name, os.Getenv("CI_JOB_ID")))
+			if err != nil {
+				return fmt.Errorf("error backing up terraform state: %w", err)
+			}
+		}
+
 		// Notify apply start
-		err := gc.TerraformApplyRunning()
+		err = gc.TerraformApplyRunning(backup)
 		if err != nil {
 			return fmt.Errorf("error sending terraform apply notification: %w", err)
 		}
+		err = gc.SetCommitStatus("apply", gitlab.StatusRunning, "Terraform apply running")
+		if err != nil {
+			return fmt.Errorf("error setting terraform apply running status: %w", err)
+		}
 
 		// Execute Apply
-		stdout, stderr, _, err := command.Run("terraform", append([]string{"apply"}, args...))
+		stdout, stderr, _, err := command.Run(tool.Binary(), tool.Args(append([]string{"apply"}, args...)))
 		if err != nil {
-			errGit := gc.TerraformApplyFailed(stripansi.Strip(string(stderr)))
+			// Tell state lock errors apart from other failures
+			output := stripansi.Strip(string(stderr))
+			var errGit error
+			if lock, ok := terraform.ParseLock(output); ok {
+				errGit = gc.TerraformStateLocked("apply", lock)
+			} else {
+				errGit = gc.TerraformApplyFailed(output)
+			}
+			if errGit != nil {
+				return fmt.Errorf("error during terraform apply: %s: %w", errGit, err)
+			}
+			errGit = gc.SetCommitStatus("apply", gitlab.StatusFailed, "Terraform apply failed")
 			if errGit != nil {
 				return fmt.Errorf("error during terraform apply: %s: %w", errGit, err)
 			}
 			return fmt.Errorf("error during terraform apply: %w", err)
 		}
 
+		units := tool.Units(stripansi.Strip(string(stdout)))
+
 		// Notify apply summary
-		err = gc.TerraformApplySummary(stripansi.Strip(string(stdout)))
+		err = gc.TerraformApplySummary(units)
 		if err != nil {
 			return fmt.Errorf("error sending apply summery notification: %w", err)
 		}
+		err = gc.SetCommitStatus("apply", gitlab.StatusSuccess, terraform.ParseApplySummary(stripansi.Strip(string(stdout))))
+		if err != nil {
+			return fmt.Errorf("error setting terraform apply summary status: %w", err)
+		}
+
+		// Export outputs for later jobs
+		if viper.GetBool("export-outputs") {
+			err = exportOutputs(tool)
+			if err != nil {
+				return fmt.Errorf("error exporting terraform outputs: %w", err)
+			}
+		}
 
 		return nil
 	},
@@ -110,8 +271,13 @@ var tfApplyCmd = &cobra.Command{
 func init() {
 	tfApplyCmd.Flags().String("gitlab-url", os.Getenv("CI_API_V4_URL"), "Gitlab API url (default: CI_API_V4_URL) [GOGCI_GITLAB_URL]")
 	tfApplyCmd.Flags().String("gitlab-token", "", "Gitlab API token [GOGCI_GITLAB_TOKEN]")
-	tfApplyCmd.Flags().Bool("approved", true, "Execute apply only when the merge request is approved [GOGCI_APPROVED]")
+	tfApplyCmd.Flags().Bool("commit-status", false, "Set a 'gogci/apply:<dir>' commit status [GOGCI_COMMIT_STATUS]")
+	tfApplyCmd.Flags().String("policy-dir", "", "Directory of policy files evaluated against the saved plan [GOGCI_POLICY_DIR]")
 	tfApplyCmd.Flags().Bool("oldest", true, "Execute apply only when no older merge requests is in open state [GOGCI_OLDEST]")
+	addApprovalFlags(tfApplyCmd, "apply")
+	tfApplyCmd.Flags().Bool("export-outputs", false, "Export outputs after a successful apply, as 'tf output' does [GOGCI_EXPORT_OUTPUTS]")
+	tfApplyCmd.Flags().String("state-backup-dir", "", "Directory receiving a state snapshot pulled before apply, e.g. a job artifact path [GOGCI_STATE_BACKUP_DIR]")
+	addOutputFlags(tfApplyCmd)
 
 	tfCmd.AddCommand(tfApplyCmd)
 }
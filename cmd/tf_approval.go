@@ -0,0 +1,48 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/Ouest-France/gogci/gitlab"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// approvalFlags are the flags of merge request approval checks, shared by
+// commands changing infrastructure or state
+var approvalFlags = []string{
+	"approved",
+	"approval-rules",
+	"approval-groups",
+	"approval-users",
+	"approval-exclude-author",
+	"approval-exclude-committers",
+	"approval-after-last-commit",
+	"approval-same-plan",
+}
+
+// approvalPolicyFromFlags returns the approval policy set by flags
+func approvalPolicyFromFlags() gitlab.ApprovalPolicy {
+	return gitlab.ApprovalPolicy{
+		Rules:             viper.GetStringSlice("approval-rules"),
+		Groups:            viper.GetStringSlice("approval-groups"),
+		Users:             viper.GetStringSlice("approval-users"),
+		ExcludeAuthor:     viper.GetBool("approval-exclude-author"),
+		ExcludeCommitters: viper.GetBool("approval-exclude-committers"),
+		AfterLastCommit:   viper.GetBool("approval-after-last-commit"),
+		SamePlan:          viper.GetBool("approval-same-plan"),
+	}
+}
+
+// addApprovalFlags defines approval check flags on cmd, action names what
+// the approval allows in help messages
+func addApprovalFlags(cmd *cobra.Command, action string) {
+	cmd.Flags().Bool("approved", true, fmt.Sprintf("Execute %s only when the merge request is approved [GOGCI_APPROVED]", action))
+	cmd.Flags().StringSlice("approval-rules", []string{}, "Approval rules that must be defined and approved [GOGCI_APPROVAL_RULES]")
+	cmd.Flags().StringSlice("approval-groups", []string{}, "Gitlab groups that must have at least one member approval [GOGCI_APPROVAL_GROUPS]")
+	cmd.Flags().StringSlice("approval-users", []string{}, "Gitlab usernames that must have approved [GOGCI_APPROVAL_USERS]")
+	cmd.Flags().Bool("approval-exclude-author", false, "Ignore approval of the merge request author [GOGCI_APPROVAL_EXCLUDE_AUTHOR]")
+	cmd.Flags().Bool("approval-exclude-committers", false, "Ignore approvals of merge request committers [GOGCI_APPROVAL_EXCLUDE_COMMITTERS]")
+	cmd.Flags().Bool("approval-after-last-commit", false, "Ignore approvals given before the latest commit [GOGCI_APPROVAL_AFTER_LAST_COMMIT]")
+	cmd.Flags().Bool("approval-same-plan", false, "Ignore approvals given on a plan that changed since [GOGCI_APPROVAL_SAME_PLAN]")
+}
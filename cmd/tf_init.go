@@ -2,10 +2,14 @@ package cmd
 
 import (
 	"fmt"
+	"io/ioutil"
 	"os"
+	"sort"
+	"strings"
 
 	"github.com/Ouest-France/gogci/command"
 	"github.com/Ouest-France/gogci/gitlab"
+	"github.com/acarl005/stripansi"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
@@ -16,6 +20,12 @@ var tfInitCmd = &cobra.Command{
 	Short: "Launch terraform init",
 	PreRunE: func(cmd *cobra.Command, args []string) error {
 
+		// Bind vault client flags
+		err := bindVaultClientFlags(cmd)
+		if err != nil {
+			return err
+		}
+
 		for _, flag := range []string{"gitlab-url", "gitlab-token"} {
 
 			// Bind viper to flag
@@ -30,6 +40,16 @@ var tfInitCmd = &cobra.Command{
 			}
 		}
 
+		// Bind optional flags
+		for _, flag := range []string{"backend-config", "backend-vault-secret"} {
+
+			// Bind viper to flag
+			err := viper.BindPFlag(flag, cmd.Flags().Lookup(flag))
+			if err != nil {
+				return fmt.Errorf("Error binding viper to flag %q: %w", flag, err)
+			}
+		}
+
 		return nil
 	},
 	RunE: func(cmd *cobra.Command, args []string) error {
@@ -37,23 +57,174 @@ var tfInitCmd = &cobra.Command{
 		// Create gitlab client
 		gc := gitlab.Client{Token: viper.GetString("gitlab-token"), URL: viper.GetString("gitlab-url")}
 
+		// Get infrastructure as code tool
+		tool, err := newTool()
+		if err != nil {
+			return err
+		}
+
+		// Collect backend configuration
+		backendArgs, secrets, cleanup, err := backendConfigArgs(viper.GetStringSlice("backend-config"), viper.GetString("backend-vault-secret"))
+		if err != nil {
+			return fmt.Errorf("failed to get backend configuration: %w", err)
+		}
+		defer cleanup()
+
 		// Execute init
-		_, _, _, err := command.Run("terraform", []string{"init"})
+		initArgs := append(append([]string{"init"}, backendArgs...), args...)
+		_, stderr, _, err := command.Run(tool.Binary(), tool.Args(initArgs))
 		if err != nil {
-			errGit := gc.TerraformInitFailed()
+			// Hide backend secrets from error output
+			output := redactSecrets(stripansi.Strip(string(stderr)), secrets)
+
+			errGit := gc.TerraformInitFailed(output)
 			if errGit != nil {
 				return fmt.Errorf("error sending terraform init failed notification: %s: %w", errGit, err)
 			}
-			return fmt.Errorf("error during terraform apply: %w", err)
+			return fmt.Errorf("error during terraform init: %w", err)
 		}
 
 		return nil
 	},
 }
 
+// backendConfigArgs returns "-backend-config" arguments from templated
+// values and Vault secret keys, along with the secret values. Secrets are
+// kept off the command line in a 0600 backend config file removed by cleanup.
+func backendConfigArgs(values []string, vaultSecret string) ([]string, []string, func(), error) {
+
+	args := []string{}
+	secrets := []string{}
+	secretConfig := map[string]string{}
+	cleanup := func() {}
+
+	// Render templated backend config values, values set by templates may
+	// hold secrets from env vars
+	for _, value := range values {
+		rendered, err := renderTemplate("backend-config", value)
+		if err != nil {
+			return nil, nil, cleanup, err
+		}
+
+		parts := strings.SplitN(rendered, "=", 2)
+		if rendered == value || len(parts) != 2 {
+			args = append(args, "-backend-config="+rendered)
+			continue
+		}
+		secretConfig[parts[0]] = parts[1]
+	}
+
+	// Get backend config from Vault secret keys
+	if vaultSecret != "" {
+		secretPath, err := renderTemplate("backend-vault-secret", vaultSecret)
+		if err != nil {
+			return nil, nil, cleanup, err
+		}
+
+		// Create vault client
+		vc, err := newAuthenticatedVaultClient()
+		if err != nil {
+			return nil, nil, cleanup, err
+		}
+
+		// Get Vault secret data
+		data, err := getSecretData(vc, secretPath)
+		if err != nil {
+			return nil, nil, cleanup, fmt.Errorf("failed to get secret from Vault: %w", err)
+		}
+
+		for key, value := range data {
+			secretConfig[key] = fmt.Sprintf("%v", value)
+		}
+	}
+
+	if len(secretConfig) == 0 {
+		return args, secrets, cleanup, nil
+	}
+
+	keys := []string{}
+	for key := range secretConfig {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	// Write secrets to a backend config file only readable by the job user
+	var content strings.Builder
+	for _, key := range keys {
+		fmt.Fprintf(&content, "%s = %s\n", key, hclString(secretConfig[key]))
+		if secretConfig[key] != "" {
+			secrets = append(secrets, secretConfig[key])
+		}
+	}
+
+	f, err := ioutil.TempFile("", "gogci-*.tfbackend")
+	if err != nil {
+		return nil, nil, cleanup, fmt.Errorf("failed to create backend config file: %w", err)
+	}
+	cleanup = func() { os.Remove(f.Name()) }
+
+	_, err = f.WriteString(content.String())
+	if errClose := f.Close(); err == nil {
+		err = errClose
+	}
+	if err == nil {
+		err = os.Chmod(f.Name(), 0600)
+	}
+	if err != nil {
+		cleanup()
+		return nil, nil, func() {}, fmt.Errorf("failed to write backend config file: %w", err)
+	}
+	args = append(args, "-backend-config="+f.Name())
+
+	// Longer secrets first, a secret may contain another one
+	sort.Slice(secrets, func(i, j int) bool { return len(secrets[i]) > len(secrets[j]) })
+
+	return args, secrets, cleanup, nil
+}
+
+// redactSecrets returns output with secret values masked
+func redactSecrets(output string, secrets []string) string {
+	for _, secret := range secrets {
+		output = strings.ReplaceAll(output, secret, "****")
+	}
+	return output
+}
+
+// hclString returns value as a quoted HCL string, template sequences escaped
+func hclString(value string) string {
+
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range value {
+		switch {
+		case r == '"' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r == '\n':
+			b.WriteString(`\n`)
+		case r == '\r':
+			b.WriteString(`\r`)
+		case r == '\t':
+			b.WriteString(`\t`)
+		case r < 0x20 || r == 0x7f:
+			fmt.Fprintf(&b, `\u%04x`, r)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	b.WriteByte('"')
+
+	// "${" and "%{" start HCL templates
+	quoted := strings.ReplaceAll(b.String(), "${", "$${")
+	return strings.ReplaceAll(quoted, "%{", "%%{")
+}
+
 func init() {
 	tfInitCmd.Flags().String("gitlab-url", os.Getenv("CI_API_V4_URL"), "Gitlab API url (default: CI_API_V4_URL) [GOGCI_GITLAB_URL]")
 	tfInitCmd.Flags().String("gitlab-token", "", "Gitlab API token [GOGCI_GITLAB_TOKEN]")
+	tfInitCmd.Flags().StringArray("backend-config", []string{}, "Backend config 'key=value' or file, templated with sprig and env vars [GOGCI_BACKEND_CONFIG]")
+	tfInitCmd.Flags().String("backend-vault-secret", "", "Vault secret path whose keys are passed as backend config [GOGCI_BACKEND_VAULT_SECRET]")
+	addVaultClientFlags(tfInitCmd)
 
 	tfCmd.AddCommand(tfInitCmd)
 }
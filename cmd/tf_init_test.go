@@ -0,0 +1,140 @@
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestHclString(t *testing.T) {
+
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"bucket", `"bucket"`},
+		{"", `""`},
+		{`pa"ss\word`, `"pa\"ss\\word"`},
+		{"line\nbreak\ttab\r", `"line\nbreak\ttab\r"`},
+		{"bell\x07", `"bell\u0007"`},
+		{"${var.secret}", `"$${var.secret}"`},
+		{"%{if true}", `"%%{if true}"`},
+		{"héllo", `"héllo"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			if got := hclString(tt.value); got != tt.want {
+				t.Errorf("hclString(%q) = %s, want %s", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBackendConfigArgs(t *testing.T) {
+
+	os.Setenv("TEST_BACKEND_PASSWORD", `s3cr"et`)
+	os.Setenv("TEST_BACKEND_TOKEN", "tok")
+	defer os.Unsetenv("TEST_BACKEND_PASSWORD")
+	defer os.Unsetenv("TEST_BACKEND_TOKEN")
+
+	tests := []struct {
+		name    string
+		values  []string
+		args    []string
+		file    string
+		secrets []string
+		wantErr bool
+	}{
+		{
+			name: "no values",
+			args: []string{},
+		},
+		{
+			name:   "plain values and files",
+			values: []string{"bucket=state", "backend.hcl"},
+			args:   []string{"-backend-config=bucket=state", "-backend-config=backend.hcl"},
+		},
+		{
+			name:    "templated values go to the backend config file",
+			values:  []string{"bucket=state", "password={{.TEST_BACKEND_PASSWORD}}", "token={{.TEST_BACKEND_TOKEN}}"},
+			args:    []string{"-backend-config=bucket=state"},
+			file:    "password = \"s3cr\\\"et\"\ntoken = \"tok\"\n",
+			secrets: []string{`s3cr"et`, "tok"},
+		},
+		{
+			name:   "templated file path",
+			values: []string{"{{.TEST_BACKEND_TOKEN}}.hcl"},
+			args:   []string{"-backend-config=tok.hcl"},
+		},
+		{
+			name:    "invalid template",
+			values:  []string{"password={{.TEST_BACKEND_PASSWORD"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args, secrets, cleanup, err := backendConfigArgs(tt.values, "")
+			defer cleanup()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("backendConfigArgs() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			// Secrets are passed by a file only readable by the job user
+			file := ""
+			if tt.file != "" {
+				last := args[len(args)-1]
+				args = args[:len(args)-1]
+				if !strings.HasPrefix(last, "-backend-config=") || !strings.HasSuffix(last, ".tfbackend") {
+					t.Fatalf("backendConfigArgs() last arg = %q, want backend config file", last)
+				}
+				file = strings.TrimPrefix(last, "-backend-config=")
+
+				info, err := os.Stat(file)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if info.Mode().Perm() != 0600 {
+					t.Errorf("backend config file mode = %v, want 0600", info.Mode().Perm())
+				}
+				content, err := ioutil.ReadFile(file)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if string(content) != tt.file {
+					t.Errorf("backend config file = %q, want %q", content, tt.file)
+				}
+			}
+
+			if !reflect.DeepEqual(args, tt.args) {
+				t.Errorf("backendConfigArgs() args = %q, want %q", args, tt.args)
+			}
+			if (len(secrets) > 0 || len(tt.secrets) > 0) && !reflect.DeepEqual(secrets, tt.secrets) {
+				t.Errorf("backendConfigArgs() secrets = %q, want %q", secrets, tt.secrets)
+			}
+
+			// Cleanup removes the backend config file
+			if file != "" {
+				cleanup()
+				if _, err := os.Stat(file); !os.IsNotExist(err) {
+					t.Errorf("backend config file %s not removed by cleanup", file)
+				}
+			}
+		})
+	}
+}
+
+func TestRedactSecrets(t *testing.T) {
+
+	got := redactSecrets("password=s3cret token=s3cret-tok", []string{"s3cret-tok", "s3cret"})
+	if want := "password=**** token=****"; got != want {
+		t.Errorf("redactSecrets() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,145 @@
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/Ouest-France/gogci/terraform"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// outputFlags are the flags of outputs export, shared by "tf output" and "tf apply"
+var outputFlags = []string{"outputs-dotenv", "outputs-prefix", "outputs-vault-secret"}
+
+// tfOutputCmd represents the "tf output" command
+var tfOutputCmd = &cobra.Command{
+	Use:   "output",
+	Short: "Export terraform outputs to a dotenv artifact and Vault",
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+
+		// Bind vault client flags
+		err := bindVaultClientFlags(cmd)
+		if err != nil {
+			return err
+		}
+
+		for _, flag := range outputFlags {
+
+			// Bind viper to flag
+			err := viper.BindPFlag(flag, cmd.Flags().Lookup(flag))
+			if err != nil {
+				return fmt.Errorf("error binding viper to flag %q: %w", flag, err)
+			}
+		}
+
+		return nil
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+
+		// Get infrastructure as code tool
+		tool, err := newTool()
+		if err != nil {
+			return err
+		}
+
+		// Select workspace
+		ws, err := workspace()
+		if err != nil {
+			return err
+		}
+		err = selectWorkspace(tool, ws, false)
+		if err != nil {
+			return err
+		}
+
+		return exportOutputs(tool)
+	},
+}
+
+// envNameRegexp matches characters not allowed in environment variable names
+var envNameRegexp = regexp.MustCompile(`[^A-Z0-9_]`)
+
+// exportOutputs writes non-sensitive outputs to a dotenv file and pushes
+// sensitive outputs to a Vault KV secret when a secret path is set
+func exportOutputs(tool terraform.Tool) error {
+
+	outputs, err := terraform.ShowOutputs(tool.Binary())
+	if err != nil {
+		return err
+	}
+
+	names := []string{}
+	for name := range outputs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	// Split outputs on sensitivity
+	var dotenv strings.Builder
+	sensitive := map[string]interface{}{}
+	for _, name := range names {
+		output := outputs[name]
+		value := output.String()
+
+		if output.Sensitive {
+			sensitive[name] = value
+			continue
+		}
+
+		// Dotenv artifacts don't support multiline values
+		if strings.Contains(value, "\n") {
+			fmt.Fprintf(os.Stderr, "skipping output %q: multiline values are not supported in dotenv\n", name)
+			continue
+		}
+
+		envName := envNameRegexp.ReplaceAllString(strings.ToUpper(viper.GetString("outputs-prefix")+name), "_")
+		fmt.Fprintf(&dotenv, "%s=%s\n", envName, value)
+	}
+
+	// Write dotenv artifact
+	err = ioutil.WriteFile(viper.GetString("outputs-dotenv"), []byte(dotenv.String()), 0644)
+	if err != nil {
+		return fmt.Errorf("failed to write outputs dotenv file: %w", err)
+	}
+
+	if len(sensitive) == 0 {
+		return nil
+	}
+	if viper.GetString("outputs-vault-secret") == "" {
+		fmt.Fprintf(os.Stderr, "skipping %d sensitive outputs: no Vault secret path set\n", len(sensitive))
+		return nil
+	}
+
+	// Push sensitive outputs to Vault
+	secretPath, err := renderTemplate("outputs-vault-secret", viper.GetString("outputs-vault-secret"))
+	if err != nil {
+		return err
+	}
+
+	// Create vault client
+	vc, err := newAuthenticatedVaultClient()
+	if err != nil {
+		return err
+	}
+
+	return pushSecretData(vc, secretPath, sensitive)
+}
+
+// addOutputFlags defines outputs export flags on cmd
+func addOutputFlags(cmd *cobra.Command) {
+	cmd.Flags().String("outputs-dotenv", "outputs.env", "Dotenv artifact file of non-sensitive outputs [GOGCI_OUTPUTS_DOTENV]")
+	cmd.Flags().String("outputs-prefix", "TF_OUTPUT_", "Prefix of outputs variable names [GOGCI_OUTPUTS_PREFIX]")
+	cmd.Flags().String("outputs-vault-secret", "", "Vault KV v2 secret path receiving sensitive outputs, templated with sprig and env vars [GOGCI_OUTPUTS_VAULT_SECRET]")
+	addVaultClientFlags(cmd)
+}
+
+func init() {
+	addOutputFlags(tfOutputCmd)
+
+	tfCmd.AddCommand(tfOutputCmd)
+}
@@ -2,10 +2,13 @@ package cmd
 
 import (
 	"fmt"
+	"io/ioutil"
 	"os"
 
 	"github.com/Ouest-France/gogci/command"
 	"github.com/Ouest-France/gogci/gitlab"
+	"github.com/Ouest-France/gogci/policy"
+	"github.com/Ouest-France/gogci/terraform"
 	"github.com/acarl005/stripansi"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
@@ -17,6 +20,12 @@ var tfPlanCmd = &cobra.Command{
 	Short: "Launch terraform plan and send output to Gitlab MR comment",
 	PreRunE: func(cmd *cobra.Command, args []string) error {
 
+		// Bind vault client flags
+		err := bindVaultClientFlags(cmd)
+		if err != nil {
+			return err
+		}
+
 		for _, flag := range []string{"gitlab-url", "gitlab-token"} {
 
 			// Bind viper to flag
@@ -31,35 +40,161 @@ var tfPlanCmd = &cobra.Command{
 			}
 		}
 
+		// Bind optional flags
+		for _, flag := range []string{"commit-status", "labels", "policy-dir", "env"} {
+
+			// Bind viper to flag
+			err := viper.BindPFlag(flag, cmd.Flags().Lookup(flag))
+			if err != nil {
+				return fmt.Errorf("Error binding viper to flag %q: %w", flag, err)
+			}
+		}
+
 		return nil
 	},
-	RunE: func(cmd *cobra.Command, args []string) error {
+	RunE: func(cmd *cobra.Command, args []string) (err error) {
+
+		// Get infrastructure as code tool
+		tool, err := newTool()
+		if err != nil {
+			return err
+		}
+
+		// Plan environments matrix
+		if envs := viper.GetStringSlice("env"); len(envs) > 0 {
+			if viper.GetString("policy-dir") != "" {
+				return fmt.Errorf("policies can't be evaluated when planning environments, --policy-dir and --env are exclusive")
+			}
+
+			gc := gitlab.Client{
+				Token:        viper.GetString("gitlab-token"),
+				URL:          viper.GetString("gitlab-url"),
+				Tool:         terraform.Describe(tool),
+				CommitStatus: viper.GetBool("commit-status"),
+				Labels:       viper.GetBool("labels"),
+			}
+			return planEnvironments(gc, tool, envs, args)
+		}
+
+		// Select workspace
+		ws, err := workspace()
+		if err != nil {
+			return err
+		}
+		err = selectWorkspace(tool, ws, true)
+		if err != nil {
+			return err
+		}
 
 		// Create gitlab client
-		gc := gitlab.Client{Token: viper.GetString("gitlab-token"), URL: viper.GetString("gitlab-url")}
+		gc := gitlab.Client{
+			Token:        viper.GetString("gitlab-token"),
+			URL:          viper.GetString("gitlab-url"),
+			Tool:         terraform.Describe(tool),
+			Workspace:    ws,
+			CommitStatus: viper.GetBool("commit-status"),
+			Labels:       viper.GetBool("labels"),
+		}
+
+		// Fail the plan status left pending or running by errors
+		defer func() {
+			if err != nil {
+				if errGit := gc.FailCommitStatus("plan", "Terraform plan failed"); errGit != nil {
+					err = fmt.Errorf("%w (error setting terraform plan failed status: %s)", err, errGit)
+				}
+			}
+		}()
 
 		// Notify plan start
-		err := gc.TerraformPlanRunning()
+		err = gc.TerraformPlanRunning()
 		if err != nil {
 			return fmt.Errorf("error sending terraform plan running notification: %w", err)
 		}
+		err = gc.SetCommitStatus("plan", gitlab.StatusRunning, "Terraform plan running")
+		if err != nil {
+			return fmt.Errorf("error setting terraform plan running status: %w", err)
+		}
+
+		// Load policies
+		rules, err := policy.Load(viper.GetString("policy-dir"))
+		if err != nil {
+			return fmt.Errorf("error loading policies: %w", err)
+		}
+
+		// Save plan to a file when policies must be evaluated
+		planArgs := append([]string{"plan"}, args...)
+		planFile := terraform.PlanFileFromArgs(args)
+		if len(rules) > 0 && planFile == "" {
+			f, err := ioutil.TempFile("", "gogci-*.tfplan")
+			if err != nil {
+				return fmt.Errorf("error creating plan file: %w", err)
+			}
+			f.Close()
+			defer os.Remove(f.Name())
+
+			planFile = f.Name()
+			planArgs = append(planArgs, "-out="+planFile)
+		}
 
 		// Execute plan
-		stdout, stderr, _, err := command.Run("terraform", append([]string{"plan"}, args...))
+		stdout, stderr, _, err := command.Run(tool.Binary(), tool.Args(planArgs))
 		if err != nil {
-			errGit := gc.TerraformPlanFailed(stripansi.Strip(string(stderr)))
+			// Tell state lock errors apart from other failures
+			output := stripansi.Strip(string(stderr))
+			var errGit error
+			if lock, ok := terraform.ParseLock(output); ok {
+				errGit = gc.TerraformStateLocked("plan", lock)
+			} else {
+				errGit = gc.TerraformPlanFailed(output)
+			}
 			if errGit != nil {
 				return fmt.Errorf("error sending terraform plan failed notification: %s: %w", errGit, err)
 			}
+			errGit = gc.SetCommitStatus("plan", gitlab.StatusFailed, "Terraform plan failed")
+			if errGit != nil {
+				return fmt.Errorf("error setting terraform plan failed status: %s: %w", errGit, err)
+			}
+			errGit = gc.SetPlanLabel(gitlab.LabelFailed)
+			if errGit != nil {
+				return fmt.Errorf("error setting terraform plan failed label: %s: %w", errGit, err)
+			}
 			return fmt.Errorf("error during terraform plan: %w", err)
 		}
+		units := tool.Units(stripansi.Strip(string(stdout)))
+
+		// Evaluate policies against JSON plan
+		results := []policy.Result{}
+		if len(rules) > 0 {
+			plan, err := terraform.ShowPlan(tool.Binary(), planFile)
+			if err != nil {
+				return fmt.Errorf("error reading terraform plan: %w", err)
+			}
+			results = policy.Evaluate(rules, plan)
+		}
 
 		// Notify plan summary
-		err = gc.TerraformPlanSummary(stripansi.Strip(string(stdout)))
+		err = gc.TerraformPlanSummary(units, results)
 		if err != nil {
 			return fmt.Errorf("error sending terraform plan summary notification: %w", err)
 		}
 
+		// Set plan status, telling changes apart from no changes
+		summary := terraform.TotalPlanSummary(units)
+		description := "Changes: " + summary.Text
+		if summary.NoChanges {
+			description = "No changes"
+		}
+		err = gc.SetCommitStatus("plan", gitlab.StatusSuccess, description)
+		if err != nil {
+			return fmt.Errorf("error setting terraform plan summary status: %w", err)
+		}
+
+		// Set plan outcome label
+		err = gc.SetPlanLabel(gitlab.PlanLabel(summary))
+		if err != nil {
+			return fmt.Errorf("error setting terraform plan label: %w", err)
+		}
+
 		return nil
 	},
 }
@@ -67,6 +202,11 @@ var tfPlanCmd = &cobra.Command{
 func init() {
 	tfPlanCmd.Flags().String("gitlab-url", os.Getenv("CI_API_V4_URL"), "Gitlab API url (default: CI_API_V4_URL) [GOGCI_GITLAB_URL]")
 	tfPlanCmd.Flags().String("gitlab-token", "", "Gitlab API token [GOGCI_GITLAB_TOKEN]")
+	tfPlanCmd.Flags().Bool("commit-status", false, "Set a 'gogci/plan:<dir>' commit status [GOGCI_COMMIT_STATUS]")
+	tfPlanCmd.Flags().String("policy-dir", "", "Directory of policy files evaluated against the plan [GOGCI_POLICY_DIR]")
+	tfPlanCmd.Flags().Bool("labels", false, "Set a 'tf::' merge request label reflecting the worst plan outcome of all dirs and workspaces [GOGCI_LABELS]")
+	tfPlanCmd.Flags().StringSlice("env", []string{}, "Plan each environment defined under 'environments' in config [GOGCI_ENV]")
+	addVaultClientFlags(tfPlanCmd)
 
 	tfCmd.AddCommand(tfPlanCmd)
 }
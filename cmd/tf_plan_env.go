@@ -0,0 +1,235 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/Ouest-France/gogci/command"
+	"github.com/Ouest-France/gogci/gitlab"
+	"github.com/Ouest-France/gogci/terraform"
+	"github.com/acarl005/stripansi"
+	"github.com/spf13/viper"
+)
+
+// environment describes how to plan the stack for one environment, read
+// from the "environments" config key
+type environment struct {
+	VarFile         string   `mapstructure:"var-file"`
+	Workspace       string   `mapstructure:"workspace"`
+	BackendConfig   []string `mapstructure:"backend-config"`
+	VaultAwsPath    string   `mapstructure:"vault-aws-path"`
+	VaultAwsStsRole string   `mapstructure:"vault-aws-sts-role"`
+}
+
+// planEnvironments plans the stack for each environment concurrently, in
+// isolated terraform data dirs, and sends a single comparison comment
+func planEnvironments(gc gitlab.Client, tool terraform.Tool, names []string, args []string) error {
+
+	// Get environments configuration
+	configs := map[string]environment{}
+	err := viper.UnmarshalKey("environments", &configs)
+	if err != nil {
+		return fmt.Errorf("error decoding environments: %w", err)
+	}
+	for _, name := range names {
+		if _, ok := configs[name]; !ok {
+			return fmt.Errorf("environment %q is not defined in config", name)
+		}
+	}
+
+	// Prepare environment variables of each environment
+	envVars := make([][]string, len(names))
+	for i, name := range names {
+		config := configs[name]
+		envVars[i] = []string{fmt.Sprintf("TF_DATA_DIR=.terraform-%s", name), "TF_IN_AUTOMATION=true"}
+
+		if config.VaultAwsStsRole != "" {
+			creds, err := vaultAwsEnv(config.VaultAwsPath, config.VaultAwsStsRole)
+			if err != nil {
+				return fmt.Errorf("failed to get AWS credentials of environment %q: %w", name, err)
+			}
+			envVars[i] = append(envVars[i], creds...)
+		}
+	}
+
+	// Notify plan start
+	err = gc.TerraformPlanRunning()
+	if err != nil {
+		return fmt.Errorf("error sending terraform plan running notification: %w", err)
+	}
+
+	// Init and select workspace sequentially as they share the lock file
+	plans := make([]gitlab.EnvironmentPlan, len(names))
+	outputs := make([][]byte, len(names))
+	secrets := make([][]string, len(names))
+	for i, name := range names {
+		config := configs[name]
+		plans[i] = gitlab.EnvironmentPlan{Name: name, Workspace: config.Workspace}
+
+		// Keep templated backend secrets off the command line
+		backendArgs, envSecrets, cleanup, err := backendConfigArgs(config.BackendConfig, "")
+		if err != nil {
+			return fmt.Errorf("failed to get backend configuration of environment %q: %w", name, err)
+		}
+		secrets[i] = envSecrets
+
+		initArgs := append([]string{"init", "-input=false", "-reconfigure"}, backendArgs...)
+		stdout, stderr, _, err := command.OutputEnv(tool.Binary(), tool.Args(initArgs), envVars[i])
+		cleanup()
+		if err != nil {
+			plans[i].Failed = true
+			outputs[i] = append(stdout, stderr...)
+			continue
+		}
+
+		if config.Workspace != "" {
+			stdout, stderr, _, err = command.OutputEnv(tool.Binary(), tool.Args([]string{"workspace", "select", config.Workspace}), envVars[i])
+			if err != nil && workspaceMissing(stderr) {
+				stdout, stderr, _, err = command.OutputEnv(tool.Binary(), tool.Args([]string{"workspace", "new", config.Workspace}), envVars[i])
+			}
+			if err != nil {
+				plans[i].Failed = true
+				outputs[i] = append(stdout, stderr...)
+			}
+		}
+	}
+
+	// Execute plans concurrently
+	var wg sync.WaitGroup
+	for i, name := range names {
+		if plans[i].Failed {
+			continue
+		}
+
+		// Each environment saves its own plan file
+		planArgs := append([]string{"plan", "-input=false"}, envPlanArgs(args, name)...)
+		if varFile := configs[name].VarFile; varFile != "" {
+			planArgs = append(planArgs, "-var-file="+varFile)
+		}
+
+		wg.Add(1)
+		go func(i int, planArgs []string) {
+			defer wg.Done()
+
+			stdout, stderr, _, err := command.OutputEnv(tool.Binary(), tool.Args(planArgs), envVars[i])
+			outputs[i] = append(stdout, stderr...)
+			if err != nil {
+				plans[i].Failed = true
+				return
+			}
+			plans[i].Units = tool.Units(stripansi.Strip(string(stdout)))
+		}(i, planArgs)
+	}
+	wg.Wait()
+
+	// Display outputs in order
+	failed := []string{}
+	for i, name := range names {
+		fmt.Printf("\n=== Environment %s ===\n\n%s\n", name, redactSecrets(string(outputs[i]), secrets[i]))
+		if plans[i].Failed {
+			failed = append(failed, name)
+		}
+	}
+
+	// Notify comparison table
+	err = gc.TerraformPlanMatrix(plans)
+	if err != nil {
+		return fmt.Errorf("error sending terraform plan matrix notification: %w", err)
+	}
+
+	// Set status of each environment and overall label
+	label := gitlab.LabelNoChanges
+	for _, plan := range plans {
+		envClient := gc
+		envClient.Workspace = plan.Key()
+
+		state, description := gitlab.StatusFailed, "Terraform plan failed"
+		planLabel := gitlab.LabelFailed
+		if !plan.Failed {
+			summary := terraform.TotalPlanSummary(plan.Units)
+			state, description = gitlab.StatusSuccess, "Changes: "+summary.Text
+			if summary.NoChanges {
+				description = "No changes"
+			}
+			planLabel = gitlab.PlanLabel(summary)
+		}
+
+		err = envClient.SetCommitStatus("plan", state, description)
+		if err != nil {
+			return fmt.Errorf("error setting terraform plan status of environment %q: %w", plan.Name, err)
+		}
+		label = gitlab.WorstLabel(label, planLabel)
+	}
+
+	err = gc.SetPlanLabel(label)
+	if err != nil {
+		return fmt.Errorf("error setting terraform plan label: %w", err)
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("error during terraform plan of environments %v", failed)
+	}
+
+	return nil
+}
+
+// envPlanArgs returns plan args with the saved plan file, if any, suffixed
+// with the environment name
+func envPlanArgs(args []string, name string) []string {
+
+	envArgs := []string{}
+	for i := 0; i < len(args); i++ {
+		switch {
+		case strings.HasPrefix(args[i], "-out="):
+			envArgs = append(envArgs, "-out="+envPlanFile(strings.TrimPrefix(args[i], "-out="), name))
+		case args[i] == "-out" && i+1 < len(args):
+			envArgs = append(envArgs, "-out", envPlanFile(args[i+1], name))
+			i++
+		default:
+			envArgs = append(envArgs, args[i])
+		}
+	}
+
+	return envArgs
+}
+
+// envPlanFile returns file suffixed with the environment name, "plan.tfplan"
+// becomes "plan-prod.tfplan"
+func envPlanFile(file, name string) string {
+	ext := filepath.Ext(file)
+	return strings.TrimSuffix(file, ext) + "-" + name + ext
+}
+
+// vaultAwsEnv returns AWS STS credentials from Vault as environment variables
+func vaultAwsEnv(path, role string) ([]string, error) {
+
+	if path == "" {
+		path = "aws_sts"
+	}
+
+	// Create vault client
+	vc, err := newAuthenticatedVaultClient()
+	if err != nil {
+		return nil, err
+	}
+
+	// Get AWS STS credentials
+	creds, err := getAwsCredentials(vc, awsCredentialsRequest{Path: path, Role: role})
+	if err != nil {
+		return nil, err
+	}
+	env := creds.Env()
+
+	// Avoid mixing credentials with the job ones
+	if os.Getenv("AWS_PROFILE") != "" {
+		env = append(env, "AWS_PROFILE=")
+	}
+	if creds.SessionToken == "" && os.Getenv("AWS_SESSION_TOKEN") != "" {
+		env = append(env, "AWS_SESSION_TOKEN=")
+	}
+
+	return env, nil
+}
@@ -0,0 +1,70 @@
+package cmd
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestEnvPlanArgs(t *testing.T) {
+
+	tests := []struct {
+		name string
+		args []string
+		want []string
+	}{
+		{
+			name: "no args",
+			args: []string{},
+			want: []string{},
+		},
+		{
+			name: "no saved plan",
+			args: []string{"-lock-timeout=60s", "-parallelism=20"},
+			want: []string{"-lock-timeout=60s", "-parallelism=20"},
+		},
+		{
+			name: "out with equal sign",
+			args: []string{"-lock=false", "-out=plan.tfplan"},
+			want: []string{"-lock=false", "-out=plan-prod.tfplan"},
+		},
+		{
+			name: "out as separate arg",
+			args: []string{"-out", "plans/plan.tfplan", "-refresh=false"},
+			want: []string{"-out", "plans/plan-prod.tfplan", "-refresh=false"},
+		},
+		{
+			name: "out without value",
+			args: []string{"-out"},
+			want: []string{"-out"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := envPlanArgs(tt.args, "prod"); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("envPlanArgs() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEnvPlanFile(t *testing.T) {
+
+	tests := []struct {
+		file string
+		want string
+	}{
+		{"plan.tfplan", "plan-prod.tfplan"},
+		{"plan", "plan-prod"},
+		{"out/plan.tfplan", "out/plan-prod.tfplan"},
+		{"plan.v1.tfplan", "plan.v1-prod.tfplan"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			if got := envPlanFile(tt.file, "prod"); got != tt.want {
+				t.Errorf("envPlanFile(%q) = %q, want %q", tt.file, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,128 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/Ouest-France/gogci/gitlab"
+	"github.com/Ouest-France/gogci/terraform"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// tfStateCmd represents the "tf state" command
+var tfStateCmd = &cobra.Command{
+	Use:   "state",
+	Short: "Terraform state helpers",
+}
+
+// tfStateRestoreCmd represents the "tf state restore" command
+var tfStateRestoreCmd = &cobra.Command{
+	Use:   "restore SNAPSHOT",
+	Short: "Push a state snapshot taken before apply back to the backend",
+	Args:  cobra.ExactArgs(1),
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+
+		for _, flag := range []string{"gitlab-url", "gitlab-token"} {
+
+			// Bind viper to flag
+			err := viper.BindPFlag(flag, cmd.Flags().Lookup(flag))
+			if err != nil {
+				return fmt.Errorf("error binding viper to flag %q: %w", flag, err)
+			}
+
+			// Check flag has a value
+			if viper.GetString(flag) == "" {
+				return fmt.Errorf("flag %q must be defined", flag)
+			}
+		}
+
+		// Bind approval flags
+		for _, flag := range approvalFlags {
+
+			// Bind viper to flag
+			err := viper.BindPFlag(flag, cmd.Flags().Lookup(flag))
+			if err != nil {
+				return fmt.Errorf("error binding viper to flag %q: %w", flag, err)
+			}
+		}
+
+		return nil
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+
+		snapshot := args[0]
+
+		// Get infrastructure as code tool
+		tool, err := newTool()
+		if err != nil {
+			return err
+		}
+
+		// Select workspace
+		ws, err := workspace()
+		if err != nil {
+			return err
+		}
+		err = selectWorkspace(tool, ws, false)
+		if err != nil {
+			return err
+		}
+
+		// Create gitlab client
+		gc := gitlab.Client{
+			Token:     viper.GetString("gitlab-token"),
+			URL:       viper.GetString("gitlab-url"),
+			Tool:      terraform.Describe(tool),
+			Workspace: ws,
+		}
+
+		// Check snapshot is a backup recorded on the merge request
+		checksum, err := terraform.StateChecksum(snapshot)
+		if err != nil {
+			return err
+		}
+		recorded, err := gc.CheckStateBackup(checksum)
+		if err != nil {
+			return fmt.Errorf("failed to check state backup: %w", err)
+		}
+		if !recorded {
+			return fmt.Errorf("snapshot %q with sha256 %s is not a state backup recorded on the merge request", snapshot, checksum)
+		}
+
+		// Check merge request approval when approved flag is set
+		if viper.GetBool("approved") {
+			approved, reasons, err := gc.CheckMergeRequestApproved(approvalPolicyFromFlags())
+			if err != nil {
+				return fmt.Errorf("failed to check merge request approval: %w", err)
+			}
+			if !approved {
+				return fmt.Errorf("merge request must be approved to restore state: %s", strings.Join(reasons, "; "))
+			}
+		}
+
+		// Push snapshot
+		err = terraform.RestoreState(tool, snapshot)
+		if err != nil {
+			return fmt.Errorf("error restoring terraform state: %w", err)
+		}
+
+		// Notify restore
+		err = gc.TerraformStateRestored(snapshot, checksum)
+		if err != nil {
+			return fmt.Errorf("error sending terraform state restored notification: %w", err)
+		}
+
+		return nil
+	},
+}
+
+func init() {
+	tfStateRestoreCmd.Flags().String("gitlab-url", os.Getenv("CI_API_V4_URL"), "Gitlab API url (default: CI_API_V4_URL) [GOGCI_GITLAB_URL]")
+	tfStateRestoreCmd.Flags().String("gitlab-token", "", "Gitlab API token [GOGCI_GITLAB_TOKEN]")
+	addApprovalFlags(tfStateRestoreCmd, "restore")
+
+	tfStateCmd.AddCommand(tfStateRestoreCmd)
+	tfCmd.AddCommand(tfStateCmd)
+}
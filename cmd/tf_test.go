@@ -0,0 +1,33 @@
+package cmd
+
+import "testing"
+
+func TestWorkspaceMissing(t *testing.T) {
+
+	tests := []struct {
+		name   string
+		stderr string
+		want   bool
+	}{
+		{
+			name:   "missing workspace",
+			stderr: "\n\x1b[31mWorkspace \"prod\" doesn't exist.\x1b[0m\n\nYou can create this workspace with the \"new\" subcommand\nor include the \"-or-create\" flag with the \"select\" subcommand.\n",
+			want:   true,
+		},
+		{
+			name:   "backend error",
+			stderr: "Error: Failed to get existing workspaces: AccessDenied: Access Denied\n",
+		},
+		{
+			name: "no output",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := workspaceMissing([]byte(tt.stderr)); got != tt.want {
+				t.Errorf("workspaceMissing() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
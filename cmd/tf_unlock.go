@@ -0,0 +1,132 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/Ouest-France/gogci/command"
+	"github.com/Ouest-France/gogci/gitlab"
+	"github.com/Ouest-France/gogci/terraform"
+	"github.com/acarl005/stripansi"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// tfUnlockCmd represents the "tf unlock" command
+var tfUnlockCmd = &cobra.Command{
+	Use:   "unlock LOCK_ID",
+	Short: "Force-unlock terraform state once allowed on the Gitlab MR",
+	Args:  cobra.ExactArgs(1),
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+
+		for _, flag := range []string{"gitlab-url", "gitlab-token"} {
+
+			// Bind viper to flag
+			err := viper.BindPFlag(flag, cmd.Flags().Lookup(flag))
+			if err != nil {
+				return fmt.Errorf("error binding viper to flag %q: %w", flag, err)
+			}
+
+			// Check flag has a value
+			if viper.GetString(flag) == "" {
+				return fmt.Errorf("flag %q must be defined", flag)
+			}
+		}
+
+		// Bind optional flags
+		for _, flag := range []string{"unlock-label", "unlock-groups", "lock-pipeline"} {
+
+			// Bind viper to flag
+			err := viper.BindPFlag(flag, cmd.Flags().Lookup(flag))
+			if err != nil {
+				return fmt.Errorf("error binding viper to flag %q: %w", flag, err)
+			}
+		}
+
+		return nil
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+
+		lockID := args[0]
+
+		// Get infrastructure as code tool
+		tool, err := newTool()
+		if err != nil {
+			return err
+		}
+
+		// Get workspace
+		ws, err := workspace()
+		if err != nil {
+			return err
+		}
+
+		// Create gitlab client
+		gc := gitlab.Client{
+			Token:     viper.GetString("gitlab-token"),
+			URL:       viper.GetString("gitlab-url"),
+			Tool:      terraform.Describe(tool),
+			Workspace: ws,
+		}
+
+		// Check unlock is allowed by label or group approval, merge request
+		// approval rules don't apply
+		allowed, err := gc.HasLabel(viper.GetString("unlock-label"))
+		if err != nil {
+			return fmt.Errorf("failed to check merge request labels: %w", err)
+		}
+		if !allowed && len(viper.GetStringSlice("unlock-groups")) > 0 {
+			allowed, _, err = gc.CheckMergeRequestApproved(gitlab.ApprovalPolicy{Groups: viper.GetStringSlice("unlock-groups")}.GroupsOnly())
+			if err != nil {
+				return fmt.Errorf("failed to check merge request approval: %w", err)
+			}
+		}
+		if !allowed {
+			return fmt.Errorf("merge request must have the %q label or an approval from groups %v to force-unlock state", viper.GetString("unlock-label"), viper.GetStringSlice("unlock-groups"))
+		}
+
+		// Check lock holder pipeline has finished
+		holder, err := gc.LockHolder(lockID, viper.GetInt("lock-pipeline"))
+		if err != nil {
+			return fmt.Errorf("failed to identify lock holder pipeline: %w", err)
+		}
+		running, err := gc.PipelineRunning(holder)
+		if err != nil {
+			return fmt.Errorf("failed to check lock holder pipeline: %w", err)
+		}
+		if running {
+			return fmt.Errorf("lock holder pipeline %d is still running", holder)
+		}
+
+		// Select workspace once unlock is allowed, it may create it
+		err = selectWorkspace(tool, ws, false)
+		if err != nil {
+			return err
+		}
+
+		// Execute force-unlock
+		_, stderr, _, err := command.Run(tool.Binary(), tool.Args([]string{"force-unlock", "-force", lockID}))
+		if err != nil {
+			return fmt.Errorf("error during terraform force-unlock: %s: %w", strings.TrimSpace(stripansi.Strip(string(stderr))), err)
+		}
+
+		// Notify unlock
+		err = gc.TerraformUnlocked(lockID)
+		if err != nil {
+			return fmt.Errorf("error sending terraform unlock notification: %w", err)
+		}
+
+		return nil
+	},
+}
+
+func init() {
+	tfUnlockCmd.Flags().String("gitlab-url", os.Getenv("CI_API_V4_URL"), "Gitlab API url (default: CI_API_V4_URL) [GOGCI_GITLAB_URL]")
+	tfUnlockCmd.Flags().String("gitlab-token", "", "Gitlab API token [GOGCI_GITLAB_TOKEN]")
+	tfUnlockCmd.Flags().String("unlock-label", "gogci::force-unlock", "Merge request label allowing force-unlock, out of the 'tf::' plan outcome labels scope [GOGCI_UNLOCK_LABEL]")
+	tfUnlockCmd.Flags().StringSlice("unlock-groups", []string{}, "Gitlab groups whose member approval allows force-unlock [GOGCI_UNLOCK_GROUPS]")
+	tfUnlockCmd.Flags().Int("lock-pipeline", 0, "Pipeline ID holding the lock (default: merge request pipeline matching the lock reported by gogci) [GOGCI_LOCK_PIPELINE]")
+
+	tfCmd.AddCommand(tfUnlockCmd)
+}
@@ -0,0 +1,476 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Ouest-France/gogci/awsconfig"
+	v4 "github.com/aws/aws-sdk-go/aws/signer/v4"
+	vault "github.com/hashicorp/vault/api"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// vaultAuthMethod is a Vault login method
+type vaultAuthMethod struct {
+	// Flags configuring the method, bound to viper by "vault login"
+	Flags []string
+
+	// Login authenticates vc and returns the auth secret
+	Login func(vc *vault.Client) (*vault.Secret, error)
+}
+
+// vaultAuthMethods are the login methods by name
+var vaultAuthMethods = map[string]vaultAuthMethod{}
+
+// registerVaultAuthMethod adds a login method to the registry
+func registerVaultAuthMethod(name string, method vaultAuthMethod) {
+	vaultAuthMethods[name] = method
+}
+
+// getVaultAuthMethod returns the login method named name
+func getVaultAuthMethod(name string) (vaultAuthMethod, error) {
+
+	method, ok := vaultAuthMethods[name]
+	if !ok {
+		return method, fmt.Errorf("unknown Vault login method %q, must be one of: %s", name, strings.Join(vaultAuthMethodNames(), ", "))
+	}
+
+	return method, nil
+}
+
+// vaultAuthMethodNames returns the sorted names of registered login methods
+func vaultAuthMethodNames() []string {
+
+	names := []string{}
+	for name := range vaultAuthMethods {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// bindVaultAuthFlags binds flags of all login methods to viper, flags shared
+// by methods once
+func bindVaultAuthFlags(cmd *cobra.Command) error {
+
+	bound := map[string]bool{}
+	for _, name := range vaultAuthMethodNames() {
+		for _, flag := range vaultAuthMethods[name].Flags {
+			if bound[flag] {
+				continue
+			}
+			bound[flag] = true
+
+			// Bind viper to flag
+			err := viper.BindPFlag(flag, cmd.Flags().Lookup(flag))
+			if err != nil {
+				return fmt.Errorf("Error binding viper to flag %q: %w", flag, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// requireFlags checks flags have a value
+func requireFlags(method string, flags ...string) error {
+
+	for _, flag := range flags {
+		if viper.GetString(flag) == "" {
+			return fmt.Errorf("flag %q must be defined for Vault login method %s", flag, method)
+		}
+	}
+
+	return nil
+}
+
+// approleLogin logs in with an AppRole role ID and secret ID, the secret ID
+// may be response wrapped
+func approleLogin(vc *vault.Client) (*vault.Secret, error) {
+
+	secretID := viper.GetString("vault-secret-id")
+	if wrappingToken := viper.GetString("vault-wrapped-secret-id"); wrappingToken != "" {
+		var err error
+		secretID, err = unwrapSecretID(vc, wrappingToken)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	approle := map[string]interface{}{
+		"role_id":   viper.GetString("vault-role-id"),
+		"secret_id": secretID,
+	}
+	secret, err := vc.Logical().Write("auth/approle/login", approle)
+	if err != nil {
+		return nil, fmt.Errorf("failed Vault login by approle: %w", err)
+	}
+
+	return secret, nil
+}
+
+// unwrapSecretID unwraps an AppRole secret ID, a wrapping token already used
+// or not created by an AppRole secret ID request means it was intercepted
+func unwrapSecretID(vc *vault.Client, wrappingToken string) (string, error) {
+
+	// Wrapping token endpoints must not use a token from env
+	vc.ClearToken()
+	defer vc.ClearToken()
+
+	// Check wrapping token origin
+	lookup, err := vc.Logical().Write("sys/wrapping/lookup", map[string]interface{}{"token": wrappingToken})
+	if err != nil {
+		return "", fmt.Errorf("failed to lookup wrapped secret ID, it may have been used or has expired: %w", err)
+	}
+	if lookup == nil {
+		return "", errors.New("no wrapping token information returned for wrapped secret ID")
+	}
+	creationPath, _ := lookup.Data["creation_path"].(string)
+	if !strings.HasPrefix(creationPath, "auth/approle/role/") || !strings.HasSuffix(creationPath, "/secret-id") {
+		return "", fmt.Errorf("wrapped secret ID was created by %q instead of an AppRole secret ID request", creationPath)
+	}
+
+	// Unwrap secret ID, a token can be unwrapped once only
+	secret, err := vc.Logical().Unwrap(wrappingToken)
+	if err != nil {
+		return "", fmt.Errorf("failed to unwrap secret ID, the wrapping token may have been intercepted: %w", err)
+	}
+	if secret == nil {
+		return "", errors.New("no data returned by secret ID unwrapping")
+	}
+	secretID, ok := secret.Data["secret_id"].(string)
+	if !ok || secretID == "" {
+		return "", errors.New("no secret ID found in unwrapped data")
+	}
+
+	return secretID, nil
+}
+
+// kubernetesLogin logs in with the pod service account token, the token is
+// read again on each attempt as kubelet rotates projected tokens
+func kubernetesLogin(vc *vault.Client) (*vault.Secret, error) {
+
+	// Get kubernetes role
+	role, err := renderTemplate("kuberole", viper.GetString("vault-kubernetes-role"))
+	if err != nil {
+		return nil, err
+	}
+
+	attempts := viper.GetInt("vault-kubernetes-login-attempts")
+	if attempts < 1 {
+		attempts = 1
+	}
+
+	for attempt := 1; ; attempt++ {
+
+		// Get kubernetes service account
+		var token string
+		token, err = readKubernetesToken(viper.GetString("vault-kubernetes-token-path"), viper.GetString("vault-kubernetes-audience"))
+		if err == nil {
+
+			// Kubernetes login
+			kubeAuth := map[string]interface{}{
+				"role": role,
+				"jwt":  token,
+			}
+			var secret *vault.Secret
+			secret, err = vc.Logical().Write(fmt.Sprintf("auth/%s/login", viper.GetString("vault-kubernetes-path")), kubeAuth)
+			if err == nil {
+				return secret, nil
+			}
+
+			// Only server and network errors are transient, a rejected login is not
+			var respErr *vault.ResponseError
+			if errors.As(err, &respErr) && respErr.StatusCode < 500 {
+				return nil, fmt.Errorf("failed Vault login by kubernetes: %w", err)
+			}
+			err = fmt.Errorf("failed Vault login by kubernetes: %w", err)
+		}
+
+		if attempt >= attempts {
+			return nil, err
+		}
+		fmt.Fprintf(os.Stderr, "kubernetes login attempt %d/%d failed, retrying: %s\n", attempt, attempts, err)
+		time.Sleep(time.Duration(attempt) * time.Second)
+	}
+}
+
+// readKubernetesToken reads a service account token and checks it is not
+// expired and, when audience is set, that it is issued for audience
+func readKubernetesToken(path, audience string) (string, error) {
+
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read kubernetes service account token: %s", err)
+	}
+	token := strings.TrimSpace(string(content))
+
+	// Decode token claims, the signature is verified by Vault
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return "", fmt.Errorf("kubernetes service account token %s is not a JWT", path)
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return "", fmt.Errorf("failed to decode kubernetes service account token claims: %w", err)
+	}
+	claims := struct {
+		Audience interface{} `json:"aud"`
+		Expiry   int64       `json:"exp"`
+	}{}
+	err = json.Unmarshal(payload, &claims)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse kubernetes service account token claims: %w", err)
+	}
+
+	// Legacy secret based tokens have no expiry
+	if claims.Expiry != 0 && time.Unix(claims.Expiry, 0).Before(time.Now()) {
+		return "", fmt.Errorf("kubernetes service account token %s expired at %s", path, time.Unix(claims.Expiry, 0).UTC().Format(time.RFC3339))
+	}
+
+	if audience == "" {
+		return token, nil
+	}
+
+	// Audience claim is a string or a list of strings
+	audiences := []string{}
+	switch aud := claims.Audience.(type) {
+	case string:
+		audiences = append(audiences, aud)
+	case []interface{}:
+		for _, a := range aud {
+			if s, ok := a.(string); ok {
+				audiences = append(audiences, s)
+			}
+		}
+	}
+	for _, aud := range audiences {
+		if aud == audience {
+			return token, nil
+		}
+	}
+
+	return "", fmt.Errorf("kubernetes service account token %s audiences %v don't include %q", path, audiences, audience)
+}
+
+// jwtLogin logs in with a JWT, usually the GitLab CI job token
+func jwtLogin(vc *vault.Client) (*vault.Secret, error) {
+
+	// Get JWT token
+	token, err := renderTemplate("jwttoken", viper.GetString("vault-jwt-token"))
+	if err != nil {
+		return nil, err
+	}
+
+	// Get JWT role
+	role, err := renderTemplate("jwtrole", viper.GetString("vault-jwt-role"))
+	if err != nil {
+		return nil, err
+	}
+
+	// JWT login
+	jwtAuth := map[string]interface{}{
+		"role": role,
+		"jwt":  token,
+	}
+	secret, err := vc.Logical().Write(fmt.Sprintf("auth/%s/login", viper.GetString("vault-jwt-path")), jwtAuth)
+	if err != nil {
+		return nil, fmt.Errorf("failed Vault login by JWT: %w", err)
+	}
+
+	return secret, nil
+}
+
+// certLogin logs in with the TLS client certificate of the Vault client
+func certLogin(vc *vault.Client) (*vault.Secret, error) {
+
+	// The certificate is presented during the TLS handshake
+	if viper.GetString("vault-client-cert") == "" && os.Getenv(vault.EnvVaultClientCert) == "" {
+		return nil, errors.New("flag \"vault-client-cert\" or VAULT_CLIENT_CERT must be defined for Vault login method cert")
+	}
+
+	// An empty name tries all roles matching the certificate
+	certAuth := map[string]interface{}{}
+	if role := viper.GetString("vault-cert-role"); role != "" {
+		certAuth["name"] = role
+	}
+	secret, err := vc.Logical().Write(fmt.Sprintf("auth/%s/login", viper.GetString("vault-cert-path")), certAuth)
+	if err != nil {
+		return nil, fmt.Errorf("failed Vault login by cert: %w", err)
+	}
+
+	return secret, nil
+}
+
+// awsLogin logs in with a signed sts:GetCallerIdentity request built from
+// the AWS credentials of the environment
+func awsLogin(vc *vault.Client) (*vault.Secret, error) {
+
+	err := requireFlags("aws", "vault-aws-auth-role")
+	if err != nil {
+		return nil, err
+	}
+
+	creds, err := awsconfig.ReadCredentials()
+	if err != nil {
+		return nil, err
+	}
+
+	// Build GetCallerIdentity request on the STS endpoint of the region
+	region := viper.GetString("vault-aws-auth-region")
+	endpoint := "https://sts.amazonaws.com/"
+	if region != "us-east-1" {
+		endpoint = fmt.Sprintf("https://sts.%s.amazonaws.com/", region)
+	}
+	body := []byte("Action=GetCallerIdentity&Version=2011-06-15")
+
+	req, err := http.NewRequest(http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create sts request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded; charset=utf-8")
+	if serverID := viper.GetString("vault-aws-auth-server-id"); serverID != "" {
+		req.Header.Set("X-Vault-AWS-IAM-Server-ID", serverID)
+	}
+
+	// Sign request, Vault forwards it to STS to identify the caller
+	_, err = v4.NewSigner(creds).Sign(req, bytes.NewReader(body), "sts", region, time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign sts request: %w", err)
+	}
+
+	headers, err := json.Marshal(req.Header)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode sts request headers: %w", err)
+	}
+
+	// AWS IAM login
+	awsAuth := map[string]interface{}{
+		"role":                    viper.GetString("vault-aws-auth-role"),
+		"iam_http_request_method": req.Method,
+		"iam_request_url":         base64.StdEncoding.EncodeToString([]byte(endpoint)),
+		"iam_request_body":        base64.StdEncoding.EncodeToString(body),
+		"iam_request_headers":     base64.StdEncoding.EncodeToString(headers),
+	}
+	secret, err := vc.Logical().Write(fmt.Sprintf("auth/%s/login", viper.GetString("vault-aws-auth-path")), awsAuth)
+	if err != nil {
+		return nil, fmt.Errorf("failed Vault login by aws: %w", err)
+	}
+
+	return secret, nil
+}
+
+// passwordLogin returns a login function for username and password methods
+// mounted on the path set by pathFlag
+func passwordLogin(method, pathFlag string) func(vc *vault.Client) (*vault.Secret, error) {
+	return func(vc *vault.Client) (*vault.Secret, error) {
+
+		err := requireFlags(method, "vault-username", "vault-password")
+		if err != nil {
+			return nil, err
+		}
+
+		passwordAuth := map[string]interface{}{
+			"password": viper.GetString("vault-password"),
+		}
+		secret, err := vc.Logical().Write(fmt.Sprintf("auth/%s/login/%s", viper.GetString(pathFlag), viper.GetString("vault-username")), passwordAuth)
+		if err != nil {
+			return nil, fmt.Errorf("failed Vault login by %s: %w", method, err)
+		}
+
+		return secret, nil
+	}
+}
+
+// tokenLogin passes an existing token through after checking it is valid
+func tokenLogin(vc *vault.Client) (*vault.Secret, error) {
+
+	token := viper.GetString("vault-token")
+	if token == "" {
+		token = os.Getenv("VAULT_TOKEN")
+	}
+	if token == "" {
+		return nil, errors.New("flag \"vault-token\" or VAULT_TOKEN must be defined for Vault login method token")
+	}
+
+	// Lookup token
+	vc.SetToken(token)
+	_, err := vc.Auth().Token().LookupSelf()
+	if err != nil {
+		return nil, fmt.Errorf("failed to lookup Vault token: %w", err)
+	}
+
+	return &vault.Secret{Auth: &vault.SecretAuth{ClientToken: token}}, nil
+}
+
+// addVaultAuthFlags defines flags of all login methods on cmd
+func addVaultAuthFlags(cmd *cobra.Command) {
+	cmd.Flags().String("vault-role-id", "", "Vault AppRole Role ID [GOGCI_VAULT_ROLE_ID]")
+	cmd.Flags().String("vault-secret-id", "", "Vault AppRole Secret ID [GOGCI_VAULT_SECRET_ID]")
+	cmd.Flags().String("vault-wrapped-secret-id", "", "Wrapping token of a Vault AppRole Secret ID, see 'gogci vault wrap' [GOGCI_VAULT_WRAPPED_SECRET_ID]")
+	cmd.Flags().String("vault-kubernetes-path", "kubernetes", "Vault Kubernetes login mount path [GOGCI_VAULT_KUBERNETES_PATH]")
+	cmd.Flags().String("vault-kubernetes-role", "", "Vault Kubernetes login role [GOGCI_VAULT_KUBERNETES_ROLE]")
+	cmd.Flags().String("vault-kubernetes-token-path", "/run/secrets/kubernetes.io/serviceaccount/token", "Kubernetes service account token file, projected tokens included [GOGCI_VAULT_KUBERNETES_TOKEN_PATH]")
+	cmd.Flags().String("vault-kubernetes-audience", "", "Audience required in the Kubernetes service account token, as configured in the Vault role [GOGCI_VAULT_KUBERNETES_AUDIENCE]")
+	cmd.Flags().Int("vault-kubernetes-login-attempts", 3, "Kubernetes login attempts on token read, Vault server or network errors, the token is read again on each attempt [GOGCI_VAULT_KUBERNETES_LOGIN_ATTEMPTS]")
+	cmd.Flags().String("vault-jwt-path", "jwt", "Vault JWT login mount path [GOGCI_VAULT_JWT_PATH]")
+	cmd.Flags().String("vault-jwt-role", "", "Vault JWT login role [GOGCI_VAULT_JWT_ROLE]")
+	cmd.Flags().String("vault-jwt-token", "", "Vault JWT token [GOGCI_VAULT_JWT_TOKEN]")
+	cmd.Flags().String("vault-cert-path", "cert", "Vault TLS certificate login mount path [GOGCI_VAULT_CERT_PATH]")
+	cmd.Flags().String("vault-cert-role", "", "Vault TLS certificate login role (default: all roles matching the certificate) [GOGCI_VAULT_CERT_ROLE]")
+	cmd.Flags().String("vault-aws-auth-path", "aws", "Vault AWS login mount path [GOGCI_VAULT_AWS_AUTH_PATH]")
+	cmd.Flags().String("vault-aws-auth-role", "", "Vault AWS IAM login role [GOGCI_VAULT_AWS_AUTH_ROLE]")
+	cmd.Flags().String("vault-aws-auth-region", "us-east-1", "Region of the STS endpoint configured in the Vault AWS login mount [GOGCI_VAULT_AWS_AUTH_REGION]")
+	cmd.Flags().String("vault-aws-auth-server-id", "", "Value of the X-Vault-AWS-IAM-Server-ID header required by the Vault AWS login mount [GOGCI_VAULT_AWS_AUTH_SERVER_ID]")
+	cmd.Flags().String("vault-username", "", "Vault userpass or LDAP username [GOGCI_VAULT_USERNAME]")
+	cmd.Flags().String("vault-password", "", "Vault userpass or LDAP password [GOGCI_VAULT_PASSWORD]")
+	cmd.Flags().String("vault-userpass-path", "userpass", "Vault userpass login mount path [GOGCI_VAULT_USERPASS_PATH]")
+	cmd.Flags().String("vault-ldap-path", "ldap", "Vault LDAP login mount path [GOGCI_VAULT_LDAP_PATH]")
+	cmd.Flags().String("vault-token", "", "Vault token checked and passed through by the token method (default: VAULT_TOKEN) [GOGCI_VAULT_TOKEN]")
+}
+
+func init() {
+	registerVaultAuthMethod("approle", vaultAuthMethod{
+		Flags: []string{"vault-role-id", "vault-secret-id", "vault-wrapped-secret-id"},
+		Login: approleLogin,
+	})
+	registerVaultAuthMethod("kubernetes", vaultAuthMethod{
+		Flags: []string{"vault-kubernetes-path", "vault-kubernetes-role", "vault-kubernetes-token-path", "vault-kubernetes-audience", "vault-kubernetes-login-attempts"},
+		Login: kubernetesLogin,
+	})
+	registerVaultAuthMethod("jwt", vaultAuthMethod{
+		Flags: []string{"vault-jwt-path", "vault-jwt-role", "vault-jwt-token"},
+		Login: jwtLogin,
+	})
+	registerVaultAuthMethod("cert", vaultAuthMethod{
+		Flags: []string{"vault-cert-path", "vault-cert-role"},
+		Login: certLogin,
+	})
+	registerVaultAuthMethod("aws", vaultAuthMethod{
+		Flags: []string{"vault-aws-auth-path", "vault-aws-auth-role", "vault-aws-auth-region", "vault-aws-auth-server-id"},
+		Login: awsLogin,
+	})
+	registerVaultAuthMethod("userpass", vaultAuthMethod{
+		Flags: []string{"vault-username", "vault-password", "vault-userpass-path"},
+		Login: passwordLogin("userpass", "vault-userpass-path"),
+	})
+	registerVaultAuthMethod("ldap", vaultAuthMethod{
+		Flags: []string{"vault-username", "vault-password", "vault-ldap-path"},
+		Login: passwordLogin("ldap", "vault-ldap-path"),
+	})
+	registerVaultAuthMethod("token", vaultAuthMethod{
+		Flags: []string{"vault-token"},
+		Login: tokenLogin,
+	})
+}
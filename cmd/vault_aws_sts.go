@@ -1,7 +1,9 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
+
 	vault "github.com/hashicorp/vault/api"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
@@ -10,10 +12,24 @@ import (
 // vaultAwsStsCmd represents the sts command
 var vaultAwsStsCmd = &cobra.Command{
 	Use:   "sts",
-	Short: "Get STS credentials from AWS vault secret backend and write them to .aws/credentials file",
+	Short: "Get STS credentials from AWS vault secret backend and export them as env vars",
 	PreRun: func(cmd *cobra.Command, args []string) {
 
-		for _, flag := range []string{"vault-addr", "vault-aws-path", "vault-aws-sts-role"} {
+		// Bind vault client flags
+		err := bindVaultClientFlags(cmd)
+		if err != nil {
+			ErrorToEval(err)
+			return
+		}
+
+		for _, flag := range []string{
+			"vault-aws-path",
+			"vault-aws-sts-role",
+			"vault-aws-type",
+			"vault-aws-ttl",
+			"vault-aws-role-arn",
+			"vault-aws-role-session-name",
+		} {
 
 			// Bind viper to flag
 			err := viper.BindPFlag(flag, cmd.Flags().Lookup(flag))
@@ -21,6 +37,9 @@ var vaultAwsStsCmd = &cobra.Command{
 				ErrorToEval(fmt.Errorf("failed to bind flag %s to viper: %s", flag, err))
 				return
 			}
+		}
+
+		for _, flag := range []string{"vault-aws-path", "vault-aws-sts-role"} {
 
 			// Check flag has a value
 			if viper.GetString(flag) == "" {
@@ -31,41 +50,128 @@ var vaultAwsStsCmd = &cobra.Command{
 	Run: func(cmd *cobra.Command, args []string) {
 
 		// Create vault client
-		vc, err := vault.NewClient(&vault.Config{Address: viper.GetString("vault-addr")})
+		vc, err := newAuthenticatedVaultClient()
 		if err != nil {
-			ErrorToEval(fmt.Errorf("failed to create vault client: %w", err))
+			ErrorToEval(err)
 			return
-
 		}
 
-		// Read vault token from env
-		token, err := getVaultToken()
+		// Get AWS credentials
+		creds, err := getAwsCredentials(vc, awsCredentialsRequest{
+			Path:            viper.GetString("vault-aws-path"),
+			Role:            viper.GetString("vault-aws-sts-role"),
+			Type:            viper.GetString("vault-aws-type"),
+			TTL:             viper.GetString("vault-aws-ttl"),
+			RoleArn:         viper.GetString("vault-aws-role-arn"),
+			RoleSessionName: viper.GetString("vault-aws-role-session-name"),
+		})
 		if err != nil {
-			ErrorToEval(fmt.Errorf("failed to get token: %s", err))
+			ErrorToEval(err)
 			return
 		}
 
-		// Set token to Vault client
-		vc.SetToken(string(token))
+		// Export AWS credentials as environment variables
+		fmt.Printf("export AWS_ACCESS_KEY_ID=%q\n", creds.AccessKey)
+		fmt.Printf("export AWS_SECRET_ACCESS_KEY=%q\n", creds.SecretKey)
+		if creds.SessionToken != "" {
+			fmt.Printf("export AWS_SESSION_TOKEN=%q\n", creds.SessionToken)
+		} else {
+			fmt.Println("unset AWS_SESSION_TOKEN")
+		}
+	},
+}
 
-		// Get AWS STS credentials
-		secret, err := vc.Logical().Write(fmt.Sprintf("%s/sts/%s", viper.GetString("vault-aws-path"), viper.GetString("vault-aws-sts-role")), nil)
-		if err != nil {
-			ErrorToEval(fmt.Errorf("failed to get STS credentials from Vault: %w", err))
-			return
+// awsCredentialsRequest describes AWS credentials requested from Vault
+type awsCredentialsRequest struct {
+	Path, Role string
+
+	// Endpoint, "sts" or "creds"
+	Type string
+
+	// Optional parameters of assumed_role and federation_token roles
+	TTL, RoleArn, RoleSessionName string
+}
+
+// awsCredentials are AWS credentials returned by Vault, SessionToken is empty
+// for IAM user credentials
+type awsCredentials struct {
+	AccessKey, SecretKey, SessionToken string
+}
+
+// Env returns credentials as environment variables
+func (c awsCredentials) Env() []string {
+	env := []string{"AWS_ACCESS_KEY_ID=" + c.AccessKey, "AWS_SECRET_ACCESS_KEY=" + c.SecretKey}
+	if c.SessionToken != "" {
+		env = append(env, "AWS_SESSION_TOKEN="+c.SessionToken)
+	}
+	return env
+}
+
+// getAwsCredentials gets AWS credentials from the Vault AWS backend
+func getAwsCredentials(vc *vault.Client, req awsCredentialsRequest) (awsCredentials, error) {
+
+	creds := awsCredentials{}
+
+	if req.Type == "" {
+		req.Type = "sts"
+	}
+	if req.Type != "sts" && req.Type != "creds" {
+		return creds, fmt.Errorf("unknown AWS credentials type %q, must be creds or sts", req.Type)
+	}
+
+	// Set optional parameters
+	data := map[string]interface{}{}
+	for key, value := range map[string]string{
+		"ttl":               req.TTL,
+		"role_arn":          req.RoleArn,
+		"role_session_name": req.RoleSessionName,
+	} {
+		if value != "" {
+			data[key] = value
 		}
+	}
 
-		// Export AWS credentials as environment variables
-		fmt.Printf("export AWS_ACCESS_KEY_ID=%q\n", secret.Data["access_key"].(string))
-		fmt.Printf("export AWS_SECRET_ACCESS_KEY=%q\n", secret.Data["secret_key"].(string))
-		fmt.Printf("export AWS_SESSION_TOKEN=%q\n", secret.Data["security_token"].(string))
-	},
+	// Get AWS credentials
+	secret, err := vc.Logical().Write(fmt.Sprintf("%s/%s/%s", req.Path, req.Type, req.Role), data)
+	if err != nil {
+		return creds, fmt.Errorf("failed to get AWS credentials from Vault: %w", err)
+	}
+	if secret == nil {
+		return creds, fmt.Errorf("no AWS credentials returned for role %s", req.Role)
+	}
+
+	var ok bool
+	if creds.AccessKey, ok = secret.Data["access_key"].(string); !ok || creds.AccessKey == "" {
+		return creds, errors.New("no access key found in AWS credentials")
+	}
+	if creds.SecretKey, ok = secret.Data["secret_key"].(string); !ok || creds.SecretKey == "" {
+		return creds, errors.New("no secret key found in AWS credentials")
+	}
+
+	// IAM user credentials have no session token
+	creds.SessionToken, _ = secret.Data["security_token"].(string)
+	if creds.SessionToken == "" {
+		creds.SessionToken, _ = secret.Data["session_token"].(string)
+	}
+
+	return creds, nil
 }
 
 func init() {
-	vaultAwsStsCmd.Flags().String("vault-addr", "", "Vault server address [GOGCI_VAULT_ADDR]")
+	addVaultClientFlags(vaultAwsStsCmd)
 	vaultAwsStsCmd.Flags().String("vault-aws-path", "aws_sts", "Vault AWS backend mount [GOGCI_VAULT_AWS_PATH]")
 	vaultAwsStsCmd.Flags().String("vault-aws-sts-role", "", "Vault AWS STS role [GOGCI_VAULT_AWS_STS_ROLE]")
+	vaultAwsStsCmd.Flags().String("vault-aws-type", "sts", "Vault AWS endpoint, 'sts' or 'creds' for IAM user, assumed_role and federation_token roles, alias --type [GOGCI_VAULT_AWS_TYPE]")
+	vaultAwsStsCmd.Flags().String("vault-aws-ttl", "", "Credentials TTL (default: role TTL), alias --ttl [GOGCI_VAULT_AWS_TTL]")
+	vaultAwsStsCmd.Flags().String("vault-aws-role-arn", "", "Role ARN to assume when the Vault role has several, alias --role-arn [GOGCI_VAULT_AWS_ROLE_ARN]")
+	vaultAwsStsCmd.Flags().String("vault-aws-role-session-name", "", "Assumed role session name, alias --role-session-name [GOGCI_VAULT_AWS_ROLE_SESSION_NAME]")
+
+	vaultAwsStsCmd.Flags().SetNormalizeFunc(flagAliases(map[string]string{
+		"type":              "vault-aws-type",
+		"ttl":               "vault-aws-ttl",
+		"role-arn":          "vault-aws-role-arn",
+		"role-session-name": "vault-aws-role-session-name",
+	}))
 
 	vaultAwsCmd.AddCommand(vaultAwsStsCmd)
 }
@@ -0,0 +1,15 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+// vaultAzureCmd represents the azure command
+var vaultAzureCmd = &cobra.Command{
+	Use:   "azure",
+	Short: "Vault Azure helpers",
+}
+
+func init() {
+	vaultCmd.AddCommand(vaultAzureCmd)
+}
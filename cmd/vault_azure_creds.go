@@ -0,0 +1,85 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// vaultAzureCredsCmd represents the "azure creds" command
+var vaultAzureCredsCmd = &cobra.Command{
+	Use:   "creds",
+	Short: "Get service principal credentials from Azure vault secret backend and export them as env vars",
+	PreRun: func(cmd *cobra.Command, args []string) {
+
+		// Bind vault client flags
+		err := bindVaultClientFlags(cmd)
+		if err != nil {
+			ErrorToEval(err)
+			return
+		}
+
+		for _, flag := range []string{"vault-azure-path", "vault-azure-role"} {
+
+			// Bind viper to flag
+			err := viper.BindPFlag(flag, cmd.Flags().Lookup(flag))
+			if err != nil {
+				ErrorToEval(fmt.Errorf("failed to bind flag %s to viper: %s", flag, err))
+				return
+			}
+
+			// Check flag has a value
+			if viper.GetString(flag) == "" {
+				ErrorToEval(fmt.Errorf("flag %s must be defined", flag))
+				return
+			}
+		}
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+
+		// Create vault client
+		vc, err := newAuthenticatedVaultClient()
+		if err != nil {
+			ErrorToEval(err)
+			return
+		}
+
+		// Get Azure credentials
+		secret, err := vc.Logical().Read(fmt.Sprintf("%s/creds/%s", viper.GetString("vault-azure-path"), viper.GetString("vault-azure-role")))
+		if err != nil {
+			ErrorToEval(fmt.Errorf("failed to get Azure credentials from Vault: %s", err))
+			return
+		}
+		if secret == nil {
+			ErrorToEval(fmt.Errorf("no Azure credentials returned for role %s", viper.GetString("vault-azure-role")))
+			return
+		}
+
+		clientID, ok := secret.Data["client_id"].(string)
+		if !ok {
+			ErrorToEval(errors.New("no client ID found in Azure credentials"))
+			return
+		}
+		clientSecret, ok := secret.Data["client_secret"].(string)
+		if !ok {
+			ErrorToEval(errors.New("no client secret found in Azure credentials"))
+			return
+		}
+
+		// Export Azure credentials as environment variables
+		fmt.Printf("export ARM_CLIENT_ID=%q\n", clientID)
+		fmt.Printf("export ARM_CLIENT_SECRET=%q\n", clientSecret)
+	},
+}
+
+func init() {
+	addVaultClientFlags(vaultAzureCredsCmd)
+	vaultAzureCredsCmd.Flags().String("vault-azure-path", "azure", "Vault Azure backend mount [GOGCI_VAULT_AZURE_PATH]")
+	vaultAzureCredsCmd.Flags().String("vault-azure-role", "", "Vault Azure role, alias --role [GOGCI_VAULT_AZURE_ROLE]")
+
+	vaultAzureCredsCmd.Flags().SetNormalizeFunc(flagAliases(map[string]string{"role": "vault-azure-role"}))
+
+	vaultAzureCmd.AddCommand(vaultAzureCredsCmd)
+}
@@ -0,0 +1,134 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	vault "github.com/hashicorp/vault/api"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// vaultClientFlags are the flags of the Vault client, shared by all commands
+// using Vault
+var vaultClientFlags = []string{
+	"vault-addr",
+	"vault-namespace",
+	"vault-cacert",
+	"vault-client-cert",
+	"vault-client-key",
+	"vault-tls-server-name",
+	"vault-max-retries",
+	"vault-timeout",
+}
+
+// newVaultClient returns a Vault client configured from the standard VAULT_*
+// env vars, overridden by gogci flags
+func newVaultClient() (*vault.Client, error) {
+
+	// Read VAULT_* env vars
+	config := vault.DefaultConfig()
+	if config.Error != nil {
+		return nil, fmt.Errorf("failed to read vault configuration: %w", config.Error)
+	}
+
+	if addr := viper.GetString("vault-addr"); addr != "" {
+		config.Address = addr
+	}
+
+	// Configure TLS, a flag may be paired with an env var for client cert and key
+	tlsConfig := &vault.TLSConfig{
+		CACert:        viper.GetString("vault-cacert"),
+		ClientCert:    viper.GetString("vault-client-cert"),
+		ClientKey:     viper.GetString("vault-client-key"),
+		TLSServerName: viper.GetString("vault-tls-server-name"),
+	}
+	if tlsConfig.CACert != "" || tlsConfig.ClientCert != "" || tlsConfig.ClientKey != "" || tlsConfig.TLSServerName != "" {
+		if tlsConfig.ClientCert == "" {
+			tlsConfig.ClientCert = os.Getenv(vault.EnvVaultClientCert)
+		}
+		if tlsConfig.ClientKey == "" {
+			tlsConfig.ClientKey = os.Getenv(vault.EnvVaultClientKey)
+		}
+
+		// ConfigureTLS only applies set fields, VAULT_SKIP_VERIFY read by
+		// DefaultConfig is kept
+		err := config.ConfigureTLS(tlsConfig)
+		if err != nil {
+			return nil, fmt.Errorf("failed to configure vault TLS: %w", err)
+		}
+	}
+
+	// Configure retries and request timeout
+	if viper.IsSet("vault-max-retries") {
+		config.MaxRetries = viper.GetInt("vault-max-retries")
+	}
+	if timeout := viper.GetString("vault-timeout"); timeout != "" {
+		d, err := time.ParseDuration(timeout)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse vault timeout %q: %w", timeout, err)
+		}
+		config.Timeout = d
+	}
+
+	// Create vault client
+	vc, err := vault.NewClient(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create vault client: %w", err)
+	}
+
+	// VAULT_NAMESPACE is read by the client, the flag overrides it
+	if namespace := viper.GetString("vault-namespace"); namespace != "" {
+		vc.SetNamespace(namespace)
+	}
+
+	return vc, nil
+}
+
+// newAuthenticatedVaultClient returns a Vault client using the job token
+func newAuthenticatedVaultClient() (*vault.Client, error) {
+
+	vc, err := newVaultClient()
+	if err != nil {
+		return nil, err
+	}
+
+	// Read vault token from env
+	token, err := getVaultToken()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get token: %w", err)
+	}
+
+	// Set token to Vault client
+	vc.SetToken(token)
+
+	return vc, nil
+}
+
+// bindVaultClientFlags binds Vault client flags to viper
+func bindVaultClientFlags(cmd *cobra.Command) error {
+
+	for _, flag := range vaultClientFlags {
+
+		// Bind viper to flag
+		err := viper.BindPFlag(flag, cmd.Flags().Lookup(flag))
+		if err != nil {
+			return fmt.Errorf("failed to bind flag %s to viper: %w", flag, err)
+		}
+	}
+
+	return nil
+}
+
+// addVaultClientFlags defines Vault client flags on cmd
+func addVaultClientFlags(cmd *cobra.Command) {
+	cmd.Flags().String("vault-addr", "", "Vault server address (default: VAULT_ADDR) [GOGCI_VAULT_ADDR]")
+	cmd.Flags().String("vault-namespace", "", "Vault Enterprise namespace (default: VAULT_NAMESPACE) [GOGCI_VAULT_NAMESPACE]")
+	cmd.Flags().String("vault-cacert", "", "CA bundle verifying the Vault server certificate (default: VAULT_CACERT) [GOGCI_VAULT_CACERT]")
+	cmd.Flags().String("vault-client-cert", "", "Client certificate for Vault TLS authentication (default: VAULT_CLIENT_CERT) [GOGCI_VAULT_CLIENT_CERT]")
+	cmd.Flags().String("vault-client-key", "", "Client key for Vault TLS authentication (default: VAULT_CLIENT_KEY) [GOGCI_VAULT_CLIENT_KEY]")
+	cmd.Flags().String("vault-tls-server-name", "", "Server name checked against the Vault certificate (default: VAULT_TLS_SERVER_NAME) [GOGCI_VAULT_TLS_SERVER_NAME]")
+	cmd.Flags().Int("vault-max-retries", 0, "Retries of failed Vault requests (default: VAULT_MAX_RETRIES or 2) [GOGCI_VAULT_MAX_RETRIES]")
+	cmd.Flags().String("vault-timeout", "", "Vault request timeout, e.g. 30s (default: VAULT_CLIENT_TIMEOUT or 60s) [GOGCI_VAULT_TIMEOUT]")
+}
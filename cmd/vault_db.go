@@ -0,0 +1,15 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+// vaultDbCmd represents the db command
+var vaultDbCmd = &cobra.Command{
+	Use:   "db",
+	Short: "Vault database helpers",
+}
+
+func init() {
+	vaultCmd.AddCommand(vaultDbCmd)
+}
@@ -0,0 +1,93 @@
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// vaultDbCleanupCmd represents the "db cleanup" command, it is meant to run
+// in after_script so errors are returned instead of being printed for eval
+var vaultDbCleanupCmd = &cobra.Command{
+	Use:   "cleanup",
+	Short: "Revoke the leases tracked by 'vault db creds'",
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+
+		// Bind vault client flags
+		err := bindVaultClientFlags(cmd)
+		if err != nil {
+			return err
+		}
+
+		for _, flag := range []string{"vault-lease-file"} {
+
+			// Bind viper to flag
+			err := viper.BindPFlag(flag, cmd.Flags().Lookup(flag))
+			if err != nil {
+				return fmt.Errorf("failed to bind flag %s to viper: %w", flag, err)
+			}
+
+			// Check flag has a value
+			if viper.GetString(flag) == "" {
+				return fmt.Errorf("flag %s must be defined", flag)
+			}
+		}
+
+		return nil
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+
+		// Read tracked leases
+		content, err := ioutil.ReadFile(viper.GetString("vault-lease-file"))
+		if os.IsNotExist(err) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read lease file: %w", err)
+		}
+
+		// Create vault client
+		vc, err := newAuthenticatedVaultClient()
+		if err != nil {
+			return err
+		}
+
+		// Revoke leases, keeping the ones that failed for a later cleanup
+		failed := []string{}
+		for _, leaseID := range strings.Fields(string(content)) {
+			err = vc.Sys().Revoke(leaseID)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "failed to revoke lease %s: %s\n", leaseID, err)
+				failed = append(failed, leaseID)
+				continue
+			}
+			fmt.Printf("Revoked lease %s\n", leaseID)
+		}
+
+		if len(failed) > 0 {
+			err = ioutil.WriteFile(viper.GetString("vault-lease-file"), []byte(strings.Join(failed, "\n")+"\n"), 0600)
+			if err != nil {
+				return fmt.Errorf("failed to write lease file: %w", err)
+			}
+			return fmt.Errorf("failed to revoke %d leases", len(failed))
+		}
+
+		err = os.Remove(viper.GetString("vault-lease-file"))
+		if err != nil {
+			return fmt.Errorf("failed to remove lease file: %w", err)
+		}
+
+		return nil
+	},
+}
+
+func init() {
+	addVaultClientFlags(vaultDbCleanupCmd)
+	vaultDbCleanupCmd.Flags().String("vault-lease-file", ".gogci-leases", "File tracking leases to revoke on cleanup [GOGCI_VAULT_LEASE_FILE]")
+
+	vaultDbCmd.AddCommand(vaultDbCleanupCmd)
+}
@@ -0,0 +1,118 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// vaultDbCredsCmd represents the "db creds" command
+var vaultDbCredsCmd = &cobra.Command{
+	Use:   "creds",
+	Short: "Get dynamic credentials from Vault database secret backend and export them as env vars",
+	PreRun: func(cmd *cobra.Command, args []string) {
+
+		// Bind vault client flags
+		err := bindVaultClientFlags(cmd)
+		if err != nil {
+			ErrorToEval(err)
+			return
+		}
+
+		for _, flag := range []string{"vault-db-path", "vault-db-role", "vault-db-env-prefix", "vault-lease-file"} {
+
+			// Bind viper to flag
+			err := viper.BindPFlag(flag, cmd.Flags().Lookup(flag))
+			if err != nil {
+				ErrorToEval(fmt.Errorf("failed to bind flag %s to viper: %s", flag, err))
+				return
+			}
+		}
+
+		for _, flag := range []string{"vault-db-path", "vault-db-role"} {
+
+			// Check flag has a value
+			if viper.GetString(flag) == "" {
+				ErrorToEval(fmt.Errorf("flag %s must be defined", flag))
+				return
+			}
+		}
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+
+		// Create vault client
+		vc, err := newAuthenticatedVaultClient()
+		if err != nil {
+			ErrorToEval(err)
+			return
+		}
+
+		// Get database credentials
+		secret, err := vc.Logical().Read(fmt.Sprintf("%s/creds/%s", viper.GetString("vault-db-path"), viper.GetString("vault-db-role")))
+		if err != nil {
+			ErrorToEval(fmt.Errorf("failed to get database credentials from Vault: %s", err))
+			return
+		}
+		if secret == nil {
+			ErrorToEval(fmt.Errorf("no database credentials returned for role %s", viper.GetString("vault-db-role")))
+			return
+		}
+
+		username, ok := secret.Data["username"].(string)
+		if !ok {
+			ErrorToEval(fmt.Errorf("no username found in database credentials"))
+			return
+		}
+		password, ok := secret.Data["password"].(string)
+		if !ok {
+			ErrorToEval(fmt.Errorf("no password found in database credentials"))
+			return
+		}
+
+		// Track lease to revoke it at the end of the job
+		if secret.LeaseID != "" {
+			err = appendLease(viper.GetString("vault-lease-file"), secret.LeaseID)
+			if err != nil {
+				ErrorToEval(err)
+				return
+			}
+		}
+
+		// Export credentials as environment variables
+		prefix := viper.GetString("vault-db-env-prefix")
+		fmt.Printf("export %sUSERNAME=%q\n", prefix, username)
+		fmt.Printf("export %sPASSWORD=%q\n", prefix, password)
+		fmt.Printf("export %sLEASE_ID=%q\n", prefix, secret.LeaseID)
+	},
+}
+
+// appendLease appends a lease ID to the lease file
+func appendLease(file, leaseID string) error {
+
+	f, err := os.OpenFile(file, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open lease file: %s", err)
+	}
+	defer f.Close()
+
+	_, err = fmt.Fprintln(f, leaseID)
+	if err != nil {
+		return fmt.Errorf("failed to write lease file: %s", err)
+	}
+
+	return nil
+}
+
+func init() {
+	addVaultClientFlags(vaultDbCredsCmd)
+	vaultDbCredsCmd.Flags().String("vault-db-path", "database", "Vault database backend mount [GOGCI_VAULT_DB_PATH]")
+	vaultDbCredsCmd.Flags().String("vault-db-role", "", "Vault database role, alias --role [GOGCI_VAULT_DB_ROLE]")
+	vaultDbCredsCmd.Flags().String("vault-db-env-prefix", "DB_", "Prefix of exported credentials env vars [GOGCI_VAULT_DB_ENV_PREFIX]")
+	vaultDbCredsCmd.Flags().String("vault-lease-file", ".gogci-leases", "File tracking leases to revoke on cleanup [GOGCI_VAULT_LEASE_FILE]")
+
+	vaultDbCredsCmd.Flags().SetNormalizeFunc(flagAliases(map[string]string{"role": "vault-db-role"}))
+
+	vaultDbCmd.AddCommand(vaultDbCredsCmd)
+}
@@ -2,7 +2,6 @@ package cmd
 
 import (
 	"fmt"
-	vault "github.com/hashicorp/vault/api"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
@@ -13,7 +12,14 @@ var vaultEnvCmd = &cobra.Command{
 	Short: "Get a Vault secret and export all keys as env vars",
 	PreRun: func(cmd *cobra.Command, args []string) {
 
-		for _, flag := range []string{"vault-addr", "vault-secret", "vault-secret-prefix"} {
+		// Bind vault client flags
+		err := bindVaultClientFlags(cmd)
+		if err != nil {
+			ErrorToEval(err)
+			return
+		}
+
+		for _, flag := range []string{"vault-secret", "vault-secret-prefix"} {
 
 			// Bind viper to flag
 			err := viper.BindPFlag(flag, cmd.Flags().Lookup(flag))
@@ -23,7 +29,7 @@ var vaultEnvCmd = &cobra.Command{
 			}
 		}
 
-		for _, flag := range []string{"vault-addr", "vault-secret"} {
+		for _, flag := range []string{"vault-secret"} {
 
 			// Check flag has a value
 			if viper.GetString(flag) == "" {
@@ -35,20 +41,11 @@ var vaultEnvCmd = &cobra.Command{
 	Run: func(cmd *cobra.Command, args []string) {
 
 		// Create vault client
-		vc, err := vault.NewClient(&vault.Config{Address: viper.GetString("vault-addr")})
-		if err != nil {
-			ErrorToEval(fmt.Errorf("failed to create vault client: %s", err))
-			return
-		}
-
-		// Read vault token from env
-		token, err := getVaultToken()
+		vc, err := newAuthenticatedVaultClient()
 		if err != nil {
-			ErrorToEval(fmt.Errorf("failed to get token: %s", err))
+			ErrorToEval(err)
 			return
 		}
-		// Set token to Vault client
-		vc.SetToken(string(token))
 
 		secretPath, err := getSecretPath()
 		if err != nil {
@@ -76,7 +73,7 @@ var vaultEnvCmd = &cobra.Command{
 }
 
 func init() {
-	vaultEnvCmd.Flags().String("vault-addr", "", "Vault server address [GOGCI_VAULT_ADDR]")
+	addVaultClientFlags(vaultEnvCmd)
 	vaultEnvCmd.Flags().String("vault-secret", "", "Vault secret path [GOGCI_VAULT_SECRET]")
 	vaultEnvCmd.Flags().String("vault-secret-prefix", "", "Vault secret path prefix [GOGCI_VAULT_SECRET_PREFIX]")
 
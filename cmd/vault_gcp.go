@@ -0,0 +1,44 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// vaultGcpCmd represents the gcp command
+var vaultGcpCmd = &cobra.Command{
+	Use:   "gcp",
+	Short: "Vault GCP helpers",
+}
+
+// gcpSecretPath returns the path of the GCP backend endpoint ("token" or
+// "key") of the roleset, static or impersonated account set by flags
+func gcpSecretPath(endpoint string) (string, error) {
+
+	accountType := viper.GetString("vault-gcp-type")
+	switch accountType {
+	case "roleset", "static-account":
+	case "impersonated-account":
+		if endpoint != "token" {
+			return "", fmt.Errorf("impersonated accounts only issue access tokens")
+		}
+	default:
+		return "", fmt.Errorf("unknown GCP account type %q, must be roleset, static-account or impersonated-account", accountType)
+	}
+
+	return fmt.Sprintf("%s/%s/%s/%s", viper.GetString("vault-gcp-path"), accountType, viper.GetString("vault-gcp-name"), endpoint), nil
+}
+
+// addGcpFlags defines flags selecting a GCP backend account on cmd
+func addGcpFlags(cmd *cobra.Command) {
+	addVaultClientFlags(cmd)
+	cmd.Flags().String("vault-gcp-path", "gcp", "Vault GCP backend mount [GOGCI_VAULT_GCP_PATH]")
+	cmd.Flags().String("vault-gcp-type", "roleset", "Vault GCP account type: roleset, static-account or impersonated-account, alias --type [GOGCI_VAULT_GCP_TYPE]")
+	cmd.Flags().String("vault-gcp-name", "", "Vault GCP roleset or account name, alias --name [GOGCI_VAULT_GCP_NAME]")
+}
+
+func init() {
+	vaultCmd.AddCommand(vaultGcpCmd)
+}
@@ -0,0 +1,108 @@
+package cmd
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// vaultGcpKeyCmd represents the "gcp key" command
+var vaultGcpKeyCmd = &cobra.Command{
+	Use:   "key",
+	Short: "Get a service account key from GCP vault secret backend, write it to a file and export its path as env var",
+	PreRun: func(cmd *cobra.Command, args []string) {
+
+		// Bind vault client flags
+		err := bindVaultClientFlags(cmd)
+		if err != nil {
+			ErrorToEval(err)
+			return
+		}
+
+		for _, flag := range []string{"vault-gcp-path", "vault-gcp-type", "vault-gcp-name", "vault-gcp-key-file"} {
+
+			// Bind viper to flag
+			err := viper.BindPFlag(flag, cmd.Flags().Lookup(flag))
+			if err != nil {
+				ErrorToEval(fmt.Errorf("failed to bind flag %s to viper: %s", flag, err))
+				return
+			}
+
+			// Check flag has a value
+			if viper.GetString(flag) == "" {
+				ErrorToEval(fmt.Errorf("flag %s must be defined", flag))
+				return
+			}
+		}
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+
+		secretPath, err := gcpSecretPath("key")
+		if err != nil {
+			ErrorToEval(err)
+			return
+		}
+
+		// Create vault client
+		vc, err := newAuthenticatedVaultClient()
+		if err != nil {
+			ErrorToEval(err)
+			return
+		}
+
+		// Get GCP service account key
+		secret, err := vc.Logical().Read(secretPath)
+		if err != nil {
+			ErrorToEval(fmt.Errorf("failed to get GCP service account key from Vault: %s", err))
+			return
+		}
+		if secret == nil {
+			ErrorToEval(fmt.Errorf("no GCP service account key returned at %s", secretPath))
+			return
+		}
+
+		keyData, ok := secret.Data["private_key_data"].(string)
+		if !ok {
+			ErrorToEval(errors.New("no private key data found in GCP service account key"))
+			return
+		}
+		key, err := base64.StdEncoding.DecodeString(keyData)
+		if err != nil {
+			ErrorToEval(fmt.Errorf("failed to decode GCP service account key: %s", err))
+			return
+		}
+
+		// Write key file readable by the job user only
+		keyFile, err := filepath.Abs(viper.GetString("vault-gcp-key-file"))
+		if err != nil {
+			ErrorToEval(fmt.Errorf("failed to get key file path: %s", err))
+			return
+		}
+		err = ioutil.WriteFile(keyFile, key, 0600)
+		if err != nil {
+			ErrorToEval(fmt.Errorf("failed to write key file: %s", err))
+			return
+		}
+
+		// Export key file path
+		fmt.Printf("export GOOGLE_APPLICATION_CREDENTIALS=%q\n", keyFile)
+	},
+}
+
+func init() {
+	addGcpFlags(vaultGcpKeyCmd)
+	vaultGcpKeyCmd.Flags().String("vault-gcp-key-file", "gcp-credentials.json", "Service account key file, alias --key-file [GOGCI_VAULT_GCP_KEY_FILE]")
+
+	vaultGcpKeyCmd.Flags().SetNormalizeFunc(flagAliases(map[string]string{
+		"type":     "vault-gcp-type",
+		"name":     "vault-gcp-name",
+		"key-file": "vault-gcp-key-file",
+	}))
+
+	vaultGcpCmd.AddCommand(vaultGcpKeyCmd)
+}
@@ -0,0 +1,87 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// vaultGcpTokenCmd represents the "gcp token" command
+var vaultGcpTokenCmd = &cobra.Command{
+	Use:   "token",
+	Short: "Get an OAuth2 access token from GCP vault secret backend and export it as env var",
+	PreRun: func(cmd *cobra.Command, args []string) {
+
+		// Bind vault client flags
+		err := bindVaultClientFlags(cmd)
+		if err != nil {
+			ErrorToEval(err)
+			return
+		}
+
+		for _, flag := range []string{"vault-gcp-path", "vault-gcp-type", "vault-gcp-name"} {
+
+			// Bind viper to flag
+			err := viper.BindPFlag(flag, cmd.Flags().Lookup(flag))
+			if err != nil {
+				ErrorToEval(fmt.Errorf("failed to bind flag %s to viper: %s", flag, err))
+				return
+			}
+
+			// Check flag has a value
+			if viper.GetString(flag) == "" {
+				ErrorToEval(fmt.Errorf("flag %s must be defined", flag))
+				return
+			}
+		}
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+
+		secretPath, err := gcpSecretPath("token")
+		if err != nil {
+			ErrorToEval(err)
+			return
+		}
+
+		// Create vault client
+		vc, err := newAuthenticatedVaultClient()
+		if err != nil {
+			ErrorToEval(err)
+			return
+		}
+
+		// Get GCP access token
+		secret, err := vc.Logical().Read(secretPath)
+		if err != nil {
+			ErrorToEval(fmt.Errorf("failed to get GCP access token from Vault: %s", err))
+			return
+		}
+		if secret == nil {
+			ErrorToEval(fmt.Errorf("no GCP access token returned at %s", secretPath))
+			return
+		}
+
+		accessToken, ok := secret.Data["token"].(string)
+		if !ok {
+			ErrorToEval(errors.New("no token found in GCP access token response"))
+			return
+		}
+
+		// Export access token for google provider and gcloud
+		fmt.Printf("export GOOGLE_OAUTH_ACCESS_TOKEN=%q\n", accessToken)
+		fmt.Printf("export CLOUDSDK_AUTH_ACCESS_TOKEN=%q\n", accessToken)
+	},
+}
+
+func init() {
+	addGcpFlags(vaultGcpTokenCmd)
+
+	vaultGcpTokenCmd.Flags().SetNormalizeFunc(flagAliases(map[string]string{
+		"type": "vault-gcp-type",
+		"name": "vault-gcp-name",
+	}))
+
+	vaultGcpCmd.AddCommand(vaultGcpTokenCmd)
+}
@@ -1,13 +1,10 @@
 package cmd
 
 import (
-	"bytes"
+	"errors"
 	"fmt"
 	"io/ioutil"
-	"text/template"
 
-	"github.com/Masterminds/sprig/v3"
-	vault "github.com/hashicorp/vault/api"
 	homedir "github.com/mitchellh/go-homedir"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
@@ -19,16 +16,20 @@ var vaultLoginCmd = &cobra.Command{
 	Short: "Get Vault token and write it to .vault-token file",
 	PreRunE: func(cmd *cobra.Command, args []string) error {
 
+		// Bind vault client flags
+		err := bindVaultClientFlags(cmd)
+		if err != nil {
+			return err
+		}
+
+		// Bind login methods flags
+		err = bindVaultAuthFlags(cmd)
+		if err != nil {
+			return err
+		}
+
 		for _, flag := range []string{
-			"vault-addr",
 			"vault-method",
-			"vault-role-id",
-			"vault-secret-id",
-			"vault-kubernetes-path",
-			"vault-kubernetes-role",
-			"vault-jwt-path",
-			"vault-jwt-role",
-			"vault-jwt-token",
 			"export-token",
 		} {
 			// Bind viper to flag
@@ -38,98 +39,36 @@ var vaultLoginCmd = &cobra.Command{
 			}
 		}
 
-		for _, flag := range []string{"vault-addr", "vault-method"} {
+		for _, flag := range []string{"vault-method"} {
 			// Check flag has a value
 			if viper.GetString(flag) == "" {
 				return fmt.Errorf("Flag %q must be defined", flag)
 			}
 		}
 
-		return nil
+		// Check login method exists
+		_, err = getVaultAuthMethod(viper.GetString("vault-method"))
+		return err
 	},
 	RunE: func(cmd *cobra.Command, args []string) error {
 
 		// Create vault client
-		vc, err := vault.NewClient(&vault.Config{Address: viper.GetString("vault-addr")})
+		vc, err := newVaultClient()
 		if err != nil {
-			return fmt.Errorf("failed to create vault client: %w", err)
+			return err
 		}
 
 		// Login
-		secret := &vault.Secret{}
-
-		switch method := viper.GetString("vault-method"); method {
-		case "approle":
-			// AppRole login
-			approle := map[string]interface{}{
-				"role_id":   viper.GetString("vault-role-id"),
-				"secret_id": viper.GetString("vault-secret-id"),
-			}
-			secret, err = vc.Logical().Write("auth/approle/login", approle)
-			if err != nil {
-				return fmt.Errorf("failed Vault login by approle: %w", err)
-			}
-
-		case "kubernetes":
-			// Get kubernetes service account
-			token, err := ioutil.ReadFile("/run/secrets/kubernetes.io/serviceaccount/token")
-			if err != nil {
-				return fmt.Errorf("failed to read kubernetes service account token: %s", err)
-			}
-
-			// Get kubernetes role
-			roleTmpl, err := template.New("kuberole").Funcs(sprig.TxtFuncMap()).Parse(viper.GetString("vault-kubernetes-role"))
-			if err != nil {
-				return fmt.Errorf("failed to create role template: %w", err)
-			}
-
-			var role bytes.Buffer
-			if err := roleTmpl.Execute(&role, nil); err != nil {
-				return err
-			}
-
-			// Kubernetes login
-			kubeAuth := map[string]interface{}{
-				"role": role.String(),
-				"jwt":  string(token),
-			}
-			secret, err = vc.Logical().Write(fmt.Sprintf("auth/%s/login", viper.GetString("vault-kubernetes-path")), kubeAuth)
-			if err != nil {
-				return fmt.Errorf("failed Vault login by kubernetes: %w", err)
-			}
-
-		case "jwt":
-			// Get JWT token
-			tokenTmpl, err := template.New("jwttoken").Funcs(sprig.TxtFuncMap()).Parse(viper.GetString("vault-jwt-token"))
-			if err != nil {
-				return fmt.Errorf("failed to create token template: %w", err)
-			}
-
-			var token bytes.Buffer
-			if err := tokenTmpl.Execute(&token, nil); err != nil {
-				return err
-			}
-
-			// Get JWT role
-			roleTmpl, err := template.New("jwtrole").Funcs(sprig.TxtFuncMap()).Parse(viper.GetString("vault-jwt-role"))
-			if err != nil {
-				return fmt.Errorf("failed to create role template: %w", err)
-			}
-
-			var role bytes.Buffer
-			if err := roleTmpl.Execute(&role, nil); err != nil {
-				return err
-			}
-
-			// JWT login
-			jwtAuth := map[string]interface{}{
-				"role": role.String(),
-				"jwt":  token.String(),
-			}
-			secret, err = vc.Logical().Write(fmt.Sprintf("auth/%s/login", viper.GetString("vault-jwt-path")), jwtAuth)
-			if err != nil {
-				return fmt.Errorf("failed Vault login by JWT: %w", err)
-			}
+		method, err := getVaultAuthMethod(viper.GetString("vault-method"))
+		if err != nil {
+			return err
+		}
+		secret, err := method.Login(vc)
+		if err != nil {
+			return err
+		}
+		if secret == nil || secret.Auth == nil || secret.Auth.ClientToken == "" {
+			return errors.New("no Vault token returned by login")
 		}
 
 		if viper.GetBool("export-token") {
@@ -153,15 +92,9 @@ var vaultLoginCmd = &cobra.Command{
 }
 
 func init() {
-	vaultLoginCmd.Flags().String("vault-addr", "", "Vault server address [GOGCI_VAULT_ADDR]")
-	vaultLoginCmd.Flags().String("vault-method", "approle", "Vault login method (default: approle) [GOGCI_VAULT_METHOD]")
-	vaultLoginCmd.Flags().String("vault-role-id", "", "Vault AppRole Role ID [GOGCI_VAULT_ROLE_ID]")
-	vaultLoginCmd.Flags().String("vault-secret-id", "", "Vault AppRole Secret ID [GOGCI_VAULT_SECRET_ID]")
-	vaultLoginCmd.Flags().String("vault-kubernetes-path", "kubernetes", "Vault Kubernetes login mount path [GOGCI_VAULT_KUBERNETES_PATH]")
-	vaultLoginCmd.Flags().String("vault-kubernetes-role", "", "Vault Kubernetes login role [GOGCI_VAULT_KUBERNETES_ROLE]")
-	vaultLoginCmd.Flags().String("vault-jwt-path", "jwt", "Vault JWT login mount path [GOGCI_VAULT_JWT_PATH]")
-	vaultLoginCmd.Flags().String("vault-jwt-role", "", "Vault JWT login role [GOGCI_VAULT_JWT_ROLE]")
-	vaultLoginCmd.Flags().String("vault-jwt-token", "", "Vault JWT token [GOGCI_VAULT_JWT_TOKEN]")
+	addVaultClientFlags(vaultLoginCmd)
+	addVaultAuthFlags(vaultLoginCmd)
+	vaultLoginCmd.Flags().String("vault-method", "approle", "Vault login method: approle, kubernetes, jwt, cert, aws, userpass, ldap or token [GOGCI_VAULT_METHOD]")
 	vaultLoginCmd.Flags().Bool("export-token", false, "Export Vault Token [GOGCI_EXPORT_TOKEN]")
 
 	vaultCmd.AddCommand(vaultLoginCmd)
@@ -0,0 +1,15 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+// vaultPkiCmd represents the pki command
+var vaultPkiCmd = &cobra.Command{
+	Use:   "pki",
+	Short: "Vault PKI helpers",
+}
+
+func init() {
+	vaultCmd.AddCommand(vaultPkiCmd)
+}
@@ -0,0 +1,220 @@
+package cmd
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// vaultPkiIssueCmd represents the "pki issue" command
+var vaultPkiIssueCmd = &cobra.Command{
+	Use:   "issue",
+	Short: "Issue a certificate from Vault PKI secret backend and write it to files or export it as env vars",
+	PreRun: func(cmd *cobra.Command, args []string) {
+
+		// Bind vault client flags
+		err := bindVaultClientFlags(cmd)
+		if err != nil {
+			ErrorToEval(err)
+			return
+		}
+
+		for _, flag := range []string{
+			"vault-pki-path",
+			"vault-pki-role",
+			"vault-pki-cn",
+			"vault-pki-alt-names",
+			"vault-pki-ttl",
+			"vault-pki-min-ttl",
+			"vault-pki-out-dir",
+		} {
+
+			// Bind viper to flag
+			err := viper.BindPFlag(flag, cmd.Flags().Lookup(flag))
+			if err != nil {
+				ErrorToEval(fmt.Errorf("failed to bind flag %s to viper: %s", flag, err))
+				return
+			}
+		}
+
+		for _, flag := range []string{"vault-pki-path", "vault-pki-role", "vault-pki-cn"} {
+
+			// Check flag has a value
+			if viper.GetString(flag) == "" {
+				ErrorToEval(fmt.Errorf("flag %s must be defined", flag))
+				return
+			}
+		}
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+
+		outDir := viper.GetString("vault-pki-out-dir")
+		certFile := filepath.Join(outDir, "cert.pem")
+		keyFile := filepath.Join(outDir, "key.pem")
+		caFile := filepath.Join(outDir, "ca.pem")
+
+		// Reuse existing certificate while it is valid long enough
+		if minTTL := viper.GetDuration("vault-pki-min-ttl"); minTTL > 0 {
+			if outDir == "" {
+				ErrorToEval(errors.New("flag vault-pki-min-ttl requires vault-pki-out-dir"))
+				return
+			}
+
+			valid, err := certificateValid(certFile, keyFile, viper.GetString("vault-pki-cn"), minTTL)
+			if err != nil {
+				ErrorToEval(err)
+				return
+			}
+			if valid {
+				exportCertificateFiles(certFile, keyFile, caFile)
+				return
+			}
+		}
+
+		// Create vault client
+		vc, err := newAuthenticatedVaultClient()
+		if err != nil {
+			ErrorToEval(err)
+			return
+		}
+
+		// Issue certificate
+		data := map[string]interface{}{"common_name": viper.GetString("vault-pki-cn")}
+		if altNames := viper.GetStringSlice("vault-pki-alt-names"); len(altNames) > 0 {
+			data["alt_names"] = strings.Join(altNames, ",")
+		}
+		if ttl := viper.GetString("vault-pki-ttl"); ttl != "" {
+			data["ttl"] = ttl
+		}
+		secret, err := vc.Logical().Write(fmt.Sprintf("%s/issue/%s", viper.GetString("vault-pki-path"), viper.GetString("vault-pki-role")), data)
+		if err != nil {
+			ErrorToEval(fmt.Errorf("failed to issue certificate from Vault: %s", err))
+			return
+		}
+		if secret == nil {
+			ErrorToEval(fmt.Errorf("no certificate returned for role %s", viper.GetString("vault-pki-role")))
+			return
+		}
+
+		cert, ok := secret.Data["certificate"].(string)
+		if !ok {
+			ErrorToEval(errors.New("no certificate found in Vault response"))
+			return
+		}
+		key, ok := secret.Data["private_key"].(string)
+		if !ok {
+			ErrorToEval(errors.New("no private key found in Vault response"))
+			return
+		}
+
+		// Prefer the full CA chain over the issuing CA
+		ca, _ := secret.Data["issuing_ca"].(string)
+		if chain, ok := secret.Data["ca_chain"].([]interface{}); ok && len(chain) > 0 {
+			certs := []string{}
+			for _, c := range chain {
+				if s, ok := c.(string); ok {
+					certs = append(certs, s)
+				}
+			}
+			ca = strings.Join(certs, "\n")
+		}
+
+		// Export certificate as environment variables
+		if outDir == "" {
+			fmt.Printf("export PKI_CERT=%q\n", cert)
+			fmt.Printf("export PKI_KEY=%q\n", key)
+			fmt.Printf("export PKI_CA=%q\n", ca)
+			return
+		}
+
+		// Write certificate files readable by the job user only
+		err = os.MkdirAll(outDir, 0700)
+		if err != nil {
+			ErrorToEval(fmt.Errorf("failed to create certificate dir: %s", err))
+			return
+		}
+		for file, content := range map[string]string{certFile: cert, keyFile: key, caFile: ca} {
+			err = ioutil.WriteFile(file, []byte(content+"\n"), 0600)
+			if err != nil {
+				ErrorToEval(fmt.Errorf("failed to write %s: %s", file, err))
+				return
+			}
+		}
+
+		exportCertificateFiles(certFile, keyFile, caFile)
+	},
+}
+
+// certificateValid returns true when the certificate file exists along with
+// its matching private key, is issued for cn and remains valid for at least
+// minTTL
+func certificateValid(file, keyFile, cn string, minTTL time.Duration) (bool, error) {
+
+	content, err := ioutil.ReadFile(file)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to read certificate: %s", err)
+	}
+
+	// Check the private key exists and matches the certificate
+	key, err := ioutil.ReadFile(keyFile)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to read private key: %s", err)
+	}
+	_, err = tls.X509KeyPair(content, key)
+	if err != nil {
+		return false, nil
+	}
+
+	block, _ := pem.Decode(content)
+	if block == nil {
+		return false, nil
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return false, nil
+	}
+
+	return cert.Subject.CommonName == cn && time.Now().Add(minTTL).Before(cert.NotAfter), nil
+}
+
+// exportCertificateFiles exports certificate file paths as environment variables
+func exportCertificateFiles(certFile, keyFile, caFile string) {
+	fmt.Printf("export PKI_CERT_FILE=%q\n", certFile)
+	fmt.Printf("export PKI_KEY_FILE=%q\n", keyFile)
+	fmt.Printf("export PKI_CA_FILE=%q\n", caFile)
+}
+
+func init() {
+	addVaultClientFlags(vaultPkiIssueCmd)
+	vaultPkiIssueCmd.Flags().String("vault-pki-path", "pki", "Vault PKI backend mount [GOGCI_VAULT_PKI_PATH]")
+	vaultPkiIssueCmd.Flags().String("vault-pki-role", "", "Vault PKI role, alias --role [GOGCI_VAULT_PKI_ROLE]")
+	vaultPkiIssueCmd.Flags().String("vault-pki-cn", "", "Certificate common name, alias --cn [GOGCI_VAULT_PKI_CN]")
+	vaultPkiIssueCmd.Flags().StringSlice("vault-pki-alt-names", []string{}, "Certificate subject alternative names [GOGCI_VAULT_PKI_ALT_NAMES]")
+	vaultPkiIssueCmd.Flags().String("vault-pki-ttl", "", "Certificate TTL (default: role TTL) [GOGCI_VAULT_PKI_TTL]")
+	vaultPkiIssueCmd.Flags().Duration("vault-pki-min-ttl", 0, "Reuse the certificate in out dir while it remains valid for this duration, alias --min-ttl [GOGCI_VAULT_PKI_MIN_TTL]")
+	vaultPkiIssueCmd.Flags().String("vault-pki-out-dir", "", "Directory receiving cert.pem, key.pem and ca.pem (default: export as env vars) [GOGCI_VAULT_PKI_OUT_DIR]")
+
+	vaultPkiIssueCmd.Flags().SetNormalizeFunc(flagAliases(map[string]string{
+		"role":    "vault-pki-role",
+		"cn":      "vault-pki-cn",
+		"min-ttl": "vault-pki-min-ttl",
+	}))
+
+	vaultPkiCmd.AddCommand(vaultPkiIssueCmd)
+}
@@ -0,0 +1,144 @@
+package cmd
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeCertificate writes a self-signed certificate and its key in dir
+func writeCertificate(t *testing.T, dir, name, cn string, ttl time.Duration) (string, string) {
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(ttl),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certFile := filepath.Join(dir, name+"-cert.pem")
+	keyFile := filepath.Join(dir, name+"-key.pem")
+	err = ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return certFile, keyFile
+}
+
+func TestCertificateValid(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "pki")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	cert, key := writeCertificate(t, dir, "valid", "app.example.com", 24*time.Hour)
+	otherCert, otherKey := writeCertificate(t, dir, "other", "app.example.com", 24*time.Hour)
+	expiringCert, expiringKey := writeCertificate(t, dir, "expiring", "app.example.com", time.Hour)
+	invalid := filepath.Join(dir, "invalid.pem")
+	if err := ioutil.WriteFile(invalid, []byte("not a certificate"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		cert    string
+		key     string
+		cn      string
+		minTTL  time.Duration
+		want    bool
+		wantErr bool
+	}{
+		{
+			name:   "valid certificate",
+			cert:   cert,
+			key:    key,
+			cn:     "app.example.com",
+			minTTL: 12 * time.Hour,
+			want:   true,
+		},
+		{
+			name: "missing certificate",
+			cert: filepath.Join(dir, "missing.pem"),
+			key:  key,
+			cn:   "app.example.com",
+		},
+		{
+			name: "missing key",
+			cert: cert,
+			key:  filepath.Join(dir, "missing.pem"),
+			cn:   "app.example.com",
+		},
+		{
+			name: "mismatched key",
+			cert: cert,
+			key:  otherKey,
+			cn:   "app.example.com",
+		},
+		{
+			name: "invalid certificate",
+			cert: invalid,
+			key:  key,
+			cn:   "app.example.com",
+		},
+		{
+			name: "other common name",
+			cert: otherCert,
+			key:  otherKey,
+			cn:   "db.example.com",
+		},
+		{
+			name:   "expiring before min TTL",
+			cert:   expiringCert,
+			key:    expiringKey,
+			cn:     "app.example.com",
+			minTTL: 2 * time.Hour,
+		},
+		{
+			name:    "unreadable certificate",
+			cert:    dir,
+			key:     key,
+			cn:      "app.example.com",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := certificateValid(tt.cert, tt.key, tt.cn, tt.minTTL)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("certificateValid() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("certificateValid() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
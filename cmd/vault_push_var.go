@@ -2,7 +2,6 @@ package cmd
 
 import (
 	"fmt"
-	vault "github.com/hashicorp/vault/api"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
@@ -12,7 +11,14 @@ var vaultPushEnv = &cobra.Command{
 	Short: "Push secret to vault",
 	PreRun: func(cmd *cobra.Command, args []string) {
 
-		for _, flag := range []string{"vault-addr", "vault-secret", "vault-secret-prefix", "vault-push-secret-key", "vault-push-secret-value"} {
+		// Bind vault client flags
+		err := bindVaultClientFlags(cmd)
+		if err != nil {
+			ErrorToEval(err)
+			return
+		}
+
+		for _, flag := range []string{"vault-secret", "vault-secret-prefix", "vault-push-secret-key", "vault-push-secret-value"} {
 
 			// Bind viper to flag
 			err := viper.BindPFlag(flag, cmd.Flags().Lookup(flag))
@@ -22,7 +28,7 @@ var vaultPushEnv = &cobra.Command{
 			}
 		}
 
-		for _, flag := range []string{"vault-addr", "vault-secret", "vault-push-secret-key", "vault-push-secret-value"} {
+		for _, flag := range []string{"vault-secret", "vault-push-secret-key", "vault-push-secret-value"} {
 
 			// Check flag has a value
 			if viper.GetString(flag) == "" {
@@ -34,22 +40,11 @@ var vaultPushEnv = &cobra.Command{
 	Run: func(cmd *cobra.Command, args []string) {
 
 		// Create vault client
-		vc, err := vault.NewClient(&vault.Config{Address: viper.GetString("vault-addr")})
-		if err != nil {
-			ErrorToEval(fmt.Errorf("failed to create vault client: %s", err))
-			return
-		}
-
-		// Read vault token from env
-
-		// Read vault token from env
-		token, err := getVaultToken()
+		vc, err := newAuthenticatedVaultClient()
 		if err != nil {
-			ErrorToEval(fmt.Errorf("failed to get token: %s", err))
+			ErrorToEval(err)
 			return
 		}
-		// Set token to Vault client
-		vc.SetToken(string(token))
 
 		secretPath, err := getSecretPath()
 		if err != nil {
@@ -57,41 +52,28 @@ var vaultPushEnv = &cobra.Command{
 			return
 		}
 
-		// Get Vault secret data
-		data, err := getSecretData(vc, secretPath)
-		if err != nil && err.Error() != "no secret found at path "+secretPath {
-			ErrorToEval(fmt.Errorf("failed to get secret from Vault: %s", err))
-			return
-		}
-		if err != nil && err.Error() == "no secret found at path "+secretPath {
-			data = make(map[string]interface{})
-		}
-
 		key := viper.GetString("vault-push-secret-key")
 		if key == "" {
-			ErrorToEval(fmt.Errorf("failed to get key to push: %s", err))
+			ErrorToEval(fmt.Errorf("failed to get key to push"))
 			return
 		}
 		value := viper.GetString("vault-push-secret-value")
 		if value == "" {
-			ErrorToEval(fmt.Errorf("failed to get value to push: %s", err))
+			ErrorToEval(fmt.Errorf("failed to get value to push"))
 			return
 		}
 
-		//merge entries
-		data[key] = value
-
-		// Get Vault secret
-		_, err = vc.Logical().Write(secretPath, map[string]interface{}{"data": data})
+		// Push secret
+		err = pushSecretData(vc, secretPath, map[string]interface{}{key: value})
 		if err != nil {
-			ErrorToEval(fmt.Errorf("failed to push secret to Vault: %s", err))
+			ErrorToEval(err)
 			return
 		}
 	},
 }
 
 func init() {
-	vaultPushEnv.Flags().String("vault-addr", "", "Vault server address [GOGCI_VAULT_ADDR]")
+	addVaultClientFlags(vaultPushEnv)
 	vaultPushEnv.Flags().String("vault-secret", "", "Vault secret path [GOGCI_VAULT_SECRET]")
 	vaultPushEnv.Flags().String("vault-secret-prefix", "", "Vault secret path prefix [GOGCI_VAULT_SECRET_PREFIX]")
 	vaultPushEnv.Flags().String("vault-push-secret-key", "", "Key of the secret to push [GOGCI_VAULT_PUSH_SECRET_KEY]")
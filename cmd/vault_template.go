@@ -0,0 +1,184 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+	"text/template"
+
+	"github.com/Masterminds/sprig/v3"
+	vault "github.com/hashicorp/vault/api"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// vaultTemplateCmd represents the "vault template" command
+var vaultTemplateCmd = &cobra.Command{
+	Use:   "template",
+	Short: "Render a config file template with Vault secrets",
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+
+		// Bind vault client flags
+		err := bindVaultClientFlags(cmd)
+		if err != nil {
+			return err
+		}
+
+		for _, flag := range []string{
+			"vault-template-input",
+			"vault-template-output",
+			"vault-secret-prefix",
+			"vault-aws-path",
+			"vault-aws-type",
+		} {
+			// Bind viper to flag
+			err := viper.BindPFlag(flag, cmd.Flags().Lookup(flag))
+			if err != nil {
+				return fmt.Errorf("failed to bind flag %s to viper: %w", flag, err)
+			}
+		}
+
+		for _, flag := range []string{"vault-template-input", "vault-template-output"} {
+			// Check flag has a value
+			if viper.GetString(flag) == "" {
+				return fmt.Errorf("flag %s must be defined", flag)
+			}
+		}
+
+		return nil
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+
+		r := &secretRenderer{
+			secrets:  map[string]map[string]interface{}{},
+			awsCreds: map[string]awsCredentials{},
+		}
+
+		return transformFile(viper.GetString("vault-template-input"), viper.GetString("vault-template-output"), r.render)
+	},
+}
+
+// secretRenderer renders templates with Vault secrets, fetched on first use
+// and cached for the render
+type secretRenderer struct {
+	vc       *vault.Client
+	secrets  map[string]map[string]interface{}
+	awsCreds map[string]awsCredentials
+}
+
+// client returns the Vault client, created on first use
+func (r *secretRenderer) client() (*vault.Client, error) {
+
+	if r.vc != nil {
+		return r.vc, nil
+	}
+
+	vc, err := newAuthenticatedVaultClient()
+	if err != nil {
+		return nil, err
+	}
+	r.vc = vc
+
+	return vc, nil
+}
+
+// secret returns key of the KV v2 secret at path, prefixed by the secret prefix
+func (r *secretRenderer) secret(path, key string) (interface{}, error) {
+
+	prefix, err := renderTemplate("prefix", viper.GetString("vault-secret-prefix"))
+	if err != nil {
+		return nil, err
+	}
+	if prefix != "" {
+		path = strings.TrimSuffix(prefix, "/") + "/" + path
+	}
+
+	data, ok := r.secrets[path]
+	if !ok {
+		vc, err := r.client()
+		if err != nil {
+			return nil, err
+		}
+
+		data, err = getSecretData(vc, path)
+		if err != nil {
+			return nil, err
+		}
+		r.secrets[path] = data
+	}
+
+	value, ok := data[key]
+	if !ok {
+		return nil, fmt.Errorf("no key %q found in secret %s", key, path)
+	}
+
+	return value, nil
+}
+
+// awsCredentials returns AWS credentials of role from the Vault AWS backend
+func (r *secretRenderer) awsCredentials(role string) (awsCredentials, error) {
+
+	creds, ok := r.awsCreds[role]
+	if ok {
+		return creds, nil
+	}
+
+	vc, err := r.client()
+	if err != nil {
+		return creds, err
+	}
+
+	creds, err = getAwsCredentials(vc, awsCredentialsRequest{
+		Path: viper.GetString("vault-aws-path"),
+		Role: role,
+		Type: viper.GetString("vault-aws-type"),
+	})
+	if err != nil {
+		return creds, err
+	}
+	r.awsCreds[role] = creds
+
+	return creds, nil
+}
+
+// render executes the template read from in, with env vars as data
+func (r *secretRenderer) render(in io.Reader, out io.Writer) error {
+
+	text, err := ioutil.ReadAll(in)
+	if err != nil {
+		return fmt.Errorf("failed to read template: %w", err)
+	}
+
+	funcs := sprig.TxtFuncMap()
+	funcs["secret"] = r.secret
+	funcs["awsCreds"] = r.awsCredentials
+
+	tmpl, err := template.New("template").Funcs(funcs).Option("missingkey=error").Parse(string(text))
+	if err != nil {
+		return fmt.Errorf("failed to create template: %w", err)
+	}
+
+	err = tmpl.Execute(out, templateEnv())
+	if err != nil {
+		return fmt.Errorf("failed to execute template: %w", err)
+	}
+
+	return nil
+}
+
+func init() {
+	addVaultClientFlags(vaultTemplateCmd)
+	vaultTemplateCmd.Flags().StringP("vault-template-input", "i", "", "Template file, Go template with sprig, secret and awsCreds functions, alias --input [GOGCI_VAULT_TEMPLATE_INPUT]")
+	vaultTemplateCmd.Flags().StringP("vault-template-output", "o", "", "Rendered file, written with 0600 permissions, alias --output [GOGCI_VAULT_TEMPLATE_OUTPUT]")
+	vaultTemplateCmd.Flags().String("vault-secret-prefix", "", "Vault secret path prefix of secret function paths, templated with sprig and env vars [GOGCI_VAULT_SECRET_PREFIX]")
+	vaultTemplateCmd.Flags().String("vault-aws-path", "aws_sts", "Vault AWS backend mount of awsCreds function [GOGCI_VAULT_AWS_PATH]")
+	vaultTemplateCmd.Flags().String("vault-aws-type", "sts", "Vault AWS endpoint of awsCreds function, 'sts' or 'creds' [GOGCI_VAULT_AWS_TYPE]")
+
+	vaultTemplateCmd.Flags().SetNormalizeFunc(flagAliases(map[string]string{
+		"input":  "vault-template-input",
+		"output": "vault-template-output",
+	}))
+
+	vaultCmd.AddCommand(vaultTemplateCmd)
+}
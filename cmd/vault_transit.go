@@ -0,0 +1,280 @@
+package cmd
+
+import (
+	"bufio"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+
+	vault "github.com/hashicorp/vault/api"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// vaultTransitCmd represents the transit command
+var vaultTransitCmd = &cobra.Command{
+	Use:   "transit",
+	Short: "Vault Transit helpers",
+}
+
+// transitFlags are the flags shared by transit subcommands
+var transitFlags = []string{"vault-transit-path", "vault-transit-key", "vault-transit-chunk-size", "vault-transit-output"}
+
+// transitHeader starts encrypted files, followed by the nonce prefix and the
+// data key wrapped by Vault
+const transitHeader = "gogci-transit:v1"
+
+// transitNonceSize is the size of the random nonce prefix, the remaining
+// 5 bytes of the AES-GCM nonce hold the chunk index and the last chunk flag
+const transitNonceSize = 7
+
+// transitEncrypt encrypts in with a data key wrapped by Vault, sealing each
+// chunk locally so that reordered, duplicated or dropped chunks are detected
+func transitEncrypt(vc *vault.Client, in io.Reader, out io.Writer) error {
+
+	chunkSize := viper.GetInt("vault-transit-chunk-size")
+	if chunkSize <= 0 {
+		return errors.New("transit chunk size must be positive")
+	}
+
+	// Generate a data key wrapped by the transit key
+	secret, err := vc.Logical().Write(transitPath("datakey/plaintext"), map[string]interface{}{"bits": 256})
+	if err != nil {
+		return fmt.Errorf("failed to generate data key with Vault: %w", err)
+	}
+	if secret == nil {
+		return errors.New("no data key returned by Vault")
+	}
+	plaintext, _ := secret.Data["plaintext"].(string)
+	wrapped, _ := secret.Data["ciphertext"].(string)
+	key, err := base64.StdEncoding.DecodeString(plaintext)
+	if err != nil || len(key) == 0 || wrapped == "" {
+		return errors.New("invalid data key returned by Vault")
+	}
+
+	prefix := make([]byte, transitNonceSize)
+	_, err = rand.Read(prefix)
+	if err != nil {
+		return fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	_, err = fmt.Fprintf(out, "%s:%s:%s\n", transitHeader, base64.StdEncoding.EncodeToString(prefix), wrapped)
+	if err != nil {
+		return fmt.Errorf("failed to write output: %w", err)
+	}
+
+	return sealChunks(key, prefix, chunkSize, in, out)
+}
+
+// transitDecrypt decrypts a file written by transitEncrypt
+func transitDecrypt(vc *vault.Client, in io.Reader, out io.Writer) error {
+
+	r := bufio.NewReader(in)
+
+	// Read header
+	line, err := r.ReadString('\n')
+	if err != nil && err != io.EOF {
+		return fmt.Errorf("failed to read input: %w", err)
+	}
+	header := strings.SplitN(strings.TrimSpace(line), ":", 4)
+	if len(header) != 4 || header[0]+":"+header[1] != transitHeader {
+		return errors.New("input is not a file encrypted by 'vault transit encrypt'")
+	}
+	prefix, err := base64.StdEncoding.DecodeString(header[2])
+	if err != nil || len(prefix) != transitNonceSize {
+		return errors.New("invalid nonce in input header")
+	}
+
+	// Unwrap data key
+	secret, err := vc.Logical().Write(transitPath("decrypt"), map[string]interface{}{"ciphertext": header[3]})
+	if err != nil {
+		return fmt.Errorf("failed to decrypt data key with Vault: %w", err)
+	}
+	if secret == nil {
+		return errors.New("no data key returned by Vault")
+	}
+	plaintext, _ := secret.Data["plaintext"].(string)
+	key, err := base64.StdEncoding.DecodeString(plaintext)
+	if err != nil || len(key) == 0 {
+		return errors.New("invalid data key returned by Vault")
+	}
+
+	return openChunks(key, prefix, r, out)
+}
+
+// transitPath returns the path of a transit operation on the configured key
+func transitPath(operation string) string {
+	return fmt.Sprintf("%s/%s/%s", viper.GetString("vault-transit-path"), operation, viper.GetString("vault-transit-key"))
+}
+
+// chunkNonce returns the nonce of a chunk, binding its index and whether it
+// is the last one
+func chunkNonce(prefix []byte, index uint32, last bool) []byte {
+	nonce := make([]byte, 0, transitNonceSize+5)
+	nonce = append(nonce, prefix...)
+	nonce = append(nonce, byte(index>>24), byte(index>>16), byte(index>>8), byte(index))
+	if last {
+		return append(nonce, 1)
+	}
+	return append(nonce, 0)
+}
+
+// sealChunks encrypts chunks of in with AES-GCM, writing one base64 sealed
+// chunk per line. An empty input still gets a last chunk.
+func sealChunks(key, prefix []byte, chunkSize int, in io.Reader, out io.Writer) error {
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return fmt.Errorf("invalid data key: %w", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return fmt.Errorf("invalid data key: %w", err)
+	}
+
+	r := bufio.NewReader(in)
+	buf := make([]byte, chunkSize)
+	for index := uint32(0); ; index++ {
+		n, err := io.ReadFull(r, buf)
+		last := err == io.EOF || err == io.ErrUnexpectedEOF
+		if err != nil && !last {
+			return fmt.Errorf("failed to read input: %w", err)
+		}
+
+		// A full chunk is the last one when nothing follows
+		if !last {
+			_, err = r.Peek(1)
+			if err != nil && err != io.EOF {
+				return fmt.Errorf("failed to read input: %w", err)
+			}
+			last = err == io.EOF
+		}
+		if !last && index == math.MaxUint32 {
+			return errors.New("too many chunks, increase the chunk size")
+		}
+
+		sealed := aead.Seal(nil, chunkNonce(prefix, index, last), buf[:n], nil)
+		_, err = fmt.Fprintln(out, base64.StdEncoding.EncodeToString(sealed))
+		if err != nil {
+			return fmt.Errorf("failed to write output: %w", err)
+		}
+
+		if last {
+			return nil
+		}
+	}
+}
+
+// openChunks decrypts the sealed chunks written by sealChunks, failing on
+// chunks out of order and on missing or trailing chunks
+func openChunks(key, prefix []byte, in *bufio.Reader, out io.Writer) error {
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return fmt.Errorf("invalid data key: %w", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return fmt.Errorf("invalid data key: %w", err)
+	}
+
+	index := uint32(0)
+	done := false
+	for {
+		line, err := in.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return fmt.Errorf("failed to read input: %w", err)
+		}
+		eof := err == io.EOF
+
+		if line = strings.TrimSpace(line); line != "" {
+			if done {
+				return errors.New("unexpected chunk after the last one")
+			}
+
+			sealed, err := base64.StdEncoding.DecodeString(line)
+			if err != nil {
+				return fmt.Errorf("failed to decode chunk %d: %w", index, err)
+			}
+
+			// Try as a following chunk, then as the last one
+			chunk, err := aead.Open(nil, chunkNonce(prefix, index, false), sealed, nil)
+			if err != nil {
+				chunk, err = aead.Open(nil, chunkNonce(prefix, index, true), sealed, nil)
+				if err != nil {
+					return fmt.Errorf("failed to decrypt chunk %d, the file is corrupted or chunks are out of order", index)
+				}
+				done = true
+			}
+
+			_, err = out.Write(chunk)
+			if err != nil {
+				return fmt.Errorf("failed to write output: %w", err)
+			}
+			index++
+		}
+
+		if eof {
+			break
+		}
+	}
+
+	if !done {
+		return errors.New("the file is truncated, last chunk is missing")
+	}
+
+	return nil
+}
+
+// bindTransitFlags binds transit flags to viper and checks required ones
+func bindTransitFlags(cmd *cobra.Command) error {
+
+	// Bind vault client flags
+	err := bindVaultClientFlags(cmd)
+	if err != nil {
+		return err
+	}
+
+	for _, flag := range transitFlags {
+
+		// Bind viper to flag
+		err := viper.BindPFlag(flag, cmd.Flags().Lookup(flag))
+		if err != nil {
+			return fmt.Errorf("failed to bind flag %s to viper: %w", flag, err)
+		}
+	}
+
+	for _, flag := range []string{"vault-transit-path", "vault-transit-key"} {
+
+		// Check flag has a value
+		if viper.GetString(flag) == "" {
+			return fmt.Errorf("flag %s must be defined", flag)
+		}
+	}
+
+	return nil
+}
+
+// addTransitFlags defines transit flags on cmd
+func addTransitFlags(cmd *cobra.Command) {
+	addVaultClientFlags(cmd)
+	cmd.Flags().String("vault-transit-path", "transit", "Vault Transit backend mount [GOGCI_VAULT_TRANSIT_PATH]")
+	cmd.Flags().String("vault-transit-key", "", "Vault Transit key name, alias --key [GOGCI_VAULT_TRANSIT_KEY]")
+	cmd.Flags().Int("vault-transit-chunk-size", 512*1024, "Size in bytes of plaintext chunks [GOGCI_VAULT_TRANSIT_CHUNK_SIZE]")
+	cmd.Flags().String("vault-transit-output", "", "Output file, alias --output [GOGCI_VAULT_TRANSIT_OUTPUT]")
+
+	cmd.Flags().SetNormalizeFunc(flagAliases(map[string]string{
+		"key":    "vault-transit-key",
+		"output": "vault-transit-output",
+	}))
+}
+
+func init() {
+	vaultCmd.AddCommand(vaultTransitCmd)
+}
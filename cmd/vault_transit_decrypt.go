@@ -0,0 +1,50 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// vaultTransitDecryptCmd represents the "transit decrypt" command
+var vaultTransitDecryptCmd = &cobra.Command{
+	Use:   "decrypt FILE",
+	Short: "Decrypt a file encrypted by 'vault transit encrypt' (default: FILE without .enc suffix)",
+	Args:  cobra.ExactArgs(1),
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		return bindTransitFlags(cmd)
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+
+		output := viper.GetString("vault-transit-output")
+		if output == "" {
+			if !strings.HasSuffix(args[0], ".enc") {
+				return fmt.Errorf("flag vault-transit-output must be defined when %s has no .enc suffix", args[0])
+			}
+			output = strings.TrimSuffix(args[0], ".enc")
+		}
+
+		vc, err := newAuthenticatedVaultClient()
+		if err != nil {
+			return err
+		}
+
+		err = transformFile(args[0], output, func(in io.Reader, out io.Writer) error {
+			return transitDecrypt(vc, in, out)
+		})
+		if err != nil {
+			return fmt.Errorf("failed to decrypt %s: %w", args[0], err)
+		}
+
+		return nil
+	},
+}
+
+func init() {
+	addTransitFlags(vaultTransitDecryptCmd)
+
+	vaultTransitCmd.AddCommand(vaultTransitDecryptCmd)
+}
@@ -0,0 +1,46 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// vaultTransitEncryptCmd represents the "transit encrypt" command
+var vaultTransitEncryptCmd = &cobra.Command{
+	Use:   "encrypt FILE",
+	Short: "Encrypt a file with a Vault Transit data key, sealing it by chunks (default: FILE.enc)",
+	Args:  cobra.ExactArgs(1),
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		return bindTransitFlags(cmd)
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+
+		output := viper.GetString("vault-transit-output")
+		if output == "" {
+			output = args[0] + ".enc"
+		}
+
+		vc, err := newAuthenticatedVaultClient()
+		if err != nil {
+			return err
+		}
+
+		err = transformFile(args[0], output, func(in io.Reader, out io.Writer) error {
+			return transitEncrypt(vc, in, out)
+		})
+		if err != nil {
+			return fmt.Errorf("failed to encrypt %s: %w", args[0], err)
+		}
+
+		return nil
+	},
+}
+
+func init() {
+	addTransitFlags(vaultTransitEncryptCmd)
+
+	vaultTransitCmd.AddCommand(vaultTransitEncryptCmd)
+}
@@ -0,0 +1,150 @@
+package cmd
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	vault "github.com/hashicorp/vault/api"
+	"github.com/spf13/viper"
+)
+
+// fakeTransit serves the transit datakey and decrypt endpoints, wrapping
+// data keys as a plain "vault:v1:" prefix
+func fakeTransit(t *testing.T) *vault.Client {
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data := map[string]interface{}{}
+		switch r.URL.Path {
+		case "/v1/transit/datakey/plaintext/test":
+			key := make([]byte, 32)
+			rand.Read(key)
+			plaintext := base64.StdEncoding.EncodeToString(key)
+			data["plaintext"] = plaintext
+			data["ciphertext"] = "vault:v1:" + plaintext
+		case "/v1/transit/decrypt/test":
+			body := map[string]string{}
+			json.NewDecoder(r.Body).Decode(&body)
+			data["plaintext"] = strings.TrimPrefix(body["ciphertext"], "vault:v1:")
+		default:
+			http.NotFound(w, r)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"data": data})
+	}))
+	t.Cleanup(server.Close)
+
+	config := vault.DefaultConfig()
+	config.Address = server.URL
+	vc, err := vault.NewClient(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	vc.SetToken("test")
+
+	return vc
+}
+
+func TestTransitRoundTrip(t *testing.T) {
+
+	vc := fakeTransit(t)
+	viper.Set("vault-transit-path", "transit")
+	viper.Set("vault-transit-key", "test")
+	viper.Set("vault-transit-chunk-size", 4)
+	defer viper.Reset()
+
+	tests := []struct {
+		name    string
+		input   string
+		tamper  func(lines []string) []string
+		wantErr bool
+	}{
+		{
+			name:  "empty",
+			input: "",
+		},
+		{
+			name:  "partial chunk",
+			input: "abc",
+		},
+		{
+			name:  "full chunks",
+			input: "abcdefgh",
+		},
+		{
+			name:  "several chunks",
+			input: "abcdefghij\nklmnop",
+		},
+		{
+			name:  "reordered chunks",
+			input: "abcdefghijkl",
+			tamper: func(lines []string) []string {
+				lines[1], lines[2] = lines[2], lines[1]
+				return lines
+			},
+			wantErr: true,
+		},
+		{
+			name:  "duplicated chunk",
+			input: "abcdefghijkl",
+			tamper: func(lines []string) []string {
+				return append([]string{lines[0], lines[1]}, lines[1:]...)
+			},
+			wantErr: true,
+		},
+		{
+			name:  "dropped middle chunk",
+			input: "abcdefghijkl",
+			tamper: func(lines []string) []string {
+				return append([]string{lines[0], lines[1]}, lines[3:]...)
+			},
+			wantErr: true,
+		},
+		{
+			name:  "dropped last chunk",
+			input: "abcdefghijkl",
+			tamper: func(lines []string) []string {
+				return lines[:len(lines)-1]
+			},
+			wantErr: true,
+		},
+		{
+			name:  "trailing chunk",
+			input: "abcdefghijkl",
+			tamper: func(lines []string) []string {
+				return append(lines, lines[1])
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			encrypted := &bytes.Buffer{}
+			err := transitEncrypt(vc, strings.NewReader(tt.input), encrypted)
+			if err != nil {
+				t.Fatalf("transitEncrypt() error = %v", err)
+			}
+
+			ciphertext := encrypted.String()
+			if tt.tamper != nil {
+				lines := strings.Split(strings.TrimSuffix(ciphertext, "\n"), "\n")
+				ciphertext = strings.Join(tt.tamper(lines), "\n") + "\n"
+			}
+
+			decrypted := &bytes.Buffer{}
+			err = transitDecrypt(vc, strings.NewReader(ciphertext), decrypted)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("transitDecrypt() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && decrypted.String() != tt.input {
+				t.Errorf("transitDecrypt() = %q, want %q", decrypted.String(), tt.input)
+			}
+		})
+	}
+}
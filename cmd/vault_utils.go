@@ -80,3 +80,29 @@ func getSecretData(vc *api.Client, secretPath string) (map[string]interface{}, e
 
 	return data, nil
 }
+
+// pushSecretData merges values into the KV v2 secret at secretPath, creating
+// the secret when it doesn't exist
+func pushSecretData(vc *api.Client, secretPath string, values map[string]interface{}) error {
+	// Get Vault secret data
+	data, err := getSecretData(vc, secretPath)
+	if err != nil && err.Error() != "no secret found at path "+secretPath {
+		return fmt.Errorf("failed to get secret from Vault: %s", err)
+	}
+	if err != nil && err.Error() == "no secret found at path "+secretPath {
+		data = make(map[string]interface{})
+	}
+
+	// Merge entries
+	for key, value := range values {
+		data[key] = value
+	}
+
+	// Write Vault secret
+	_, err = vc.Logical().Write(secretPath, map[string]interface{}{"data": data})
+	if err != nil {
+		return fmt.Errorf("failed to push secret to Vault: %s", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,81 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// vaultWrapCmd represents the "vault wrap" command
+var vaultWrapCmd = &cobra.Command{
+	Use:   "wrap",
+	Short: "Create a response wrapped AppRole secret ID and export its wrapping token",
+	PreRun: func(cmd *cobra.Command, args []string) {
+
+		// Bind vault client flags
+		err := bindVaultClientFlags(cmd)
+		if err != nil {
+			ErrorToEval(err)
+			return
+		}
+
+		for _, flag := range []string{"vault-approle-role", "vault-wrap-ttl"} {
+
+			// Bind viper to flag
+			err := viper.BindPFlag(flag, cmd.Flags().Lookup(flag))
+			if err != nil {
+				ErrorToEval(fmt.Errorf("failed to bind flag %s to viper: %s", flag, err))
+				return
+			}
+
+			// Check flag has a value
+			if viper.GetString(flag) == "" {
+				ErrorToEval(fmt.Errorf("flag %s must be defined", flag))
+				return
+			}
+		}
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+
+		// Create vault client
+		vc, err := newAuthenticatedVaultClient()
+		if err != nil {
+			ErrorToEval(err)
+			return
+		}
+
+		// Wrap all responses of this client
+		vc.SetWrappingLookupFunc(func(operation, path string) string {
+			return viper.GetString("vault-wrap-ttl")
+		})
+
+		// Create secret ID
+		secret, err := vc.Logical().Write(fmt.Sprintf("auth/approle/role/%s/secret-id", viper.GetString("vault-approle-role")), nil)
+		if err != nil {
+			ErrorToEval(fmt.Errorf("failed to create wrapped secret ID: %s", err))
+			return
+		}
+		if secret == nil || secret.WrapInfo == nil || secret.WrapInfo.Token == "" {
+			ErrorToEval(errors.New("no wrapping token returned for secret ID"))
+			return
+		}
+
+		// Export wrapping token, consumed by "vault login --vault-method approle"
+		fmt.Printf("export GOGCI_VAULT_WRAPPED_SECRET_ID=%q\n", secret.WrapInfo.Token)
+	},
+}
+
+func init() {
+	addVaultClientFlags(vaultWrapCmd)
+	vaultWrapCmd.Flags().String("vault-approle-role", "", "Vault AppRole role name, alias --role [GOGCI_VAULT_APPROLE_ROLE]")
+	vaultWrapCmd.Flags().String("vault-wrap-ttl", "5m", "Wrapping token TTL, alias --ttl [GOGCI_VAULT_WRAP_TTL]")
+
+	vaultWrapCmd.Flags().SetNormalizeFunc(flagAliases(map[string]string{
+		"role": "vault-approle-role",
+		"ttl":  "vault-wrap-ttl",
+	}))
+
+	vaultCmd.AddCommand(vaultWrapCmd)
+}
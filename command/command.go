@@ -57,3 +57,32 @@ func Run(name string, args []string) (stdout, stderr []byte, code int, err error
 
 	return stdoutBuf.Bytes(), stderrBuf.Bytes(), cmd.ProcessState.ExitCode(), nil
 }
+
+// Output runs a command and captures its output without displaying it
+func Output(name string, args []string) (stdout, stderr []byte, code int, err error) {
+	return OutputEnv(name, args, nil)
+}
+
+// OutputEnv runs a command with additional environment variables and
+// captures its output without displaying it
+func OutputEnv(name string, args []string, env []string) (stdout, stderr []byte, code int, err error) {
+
+	// Create command
+	cmd := exec.Command(name, args...)
+	if len(env) > 0 {
+		cmd.Env = append(os.Environ(), env...)
+	}
+
+	// Capture stdout/stderr
+	var stdoutBuf, stderrBuf bytes.Buffer
+	cmd.Stdout = &stdoutBuf
+	cmd.Stderr = &stderrBuf
+
+	// Execute command
+	err = cmd.Run()
+	if err != nil {
+		return stdoutBuf.Bytes(), stderrBuf.Bytes(), cmd.ProcessState.ExitCode(), fmt.Errorf("command execution failed: %w", err)
+	}
+
+	return stdoutBuf.Bytes(), stderrBuf.Bytes(), cmd.ProcessState.ExitCode(), nil
+}
@@ -1,53 +1,488 @@
 package gitlab
 
 import (
-	"errors"
 	"fmt"
-	"os"
-	"strconv"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/xanzy/go-gitlab"
 )
 
-func (c *Client) CheckMergeRequestApproved() (bool, error) {
+// ApprovalPolicy holds requirements checked on top of Gitlab approval rules
+type ApprovalPolicy struct {
+	Rules             []string // Approval rules that must exist and be approved
+	Groups            []string // Groups that must have at least one member approval
+	Users             []string // Usernames that must have approved
+	ExcludeAuthor     bool     // Ignore approval of the merge request author
+	ExcludeCommitters bool     // Ignore approvals of merge request committers
+	AfterLastCommit   bool     // Ignore approvals given before the latest commit
+	SamePlan          bool     // Ignore approvals given on a different plan than the current one
+
+	// Groups that must approve, with the changed protected resources requiring it
+	ProtectedGroups map[string][]string
+
+	// Skip merge request approval rules, checking policy groups only
+	groupsOnly bool
+}
+
+// ProtectedOnly returns the policy restricted to protected resources group
+// approvals, approval exclusions still apply
+func (p ApprovalPolicy) ProtectedOnly() ApprovalPolicy {
+	p.Rules, p.Groups, p.Users = nil, nil, nil
+	p.groupsOnly = true
+	return p
+}
+
+// GroupsOnly returns the policy restricted to Groups member approvals,
+// approval exclusions still apply
+func (p ApprovalPolicy) GroupsOnly() ApprovalPolicy {
+	p.Rules, p.Users, p.ProtectedGroups = nil, nil, nil
+	p.groupsOnly = true
+	return p
+}
+
+func (c *Client) CheckMergeRequestApproved(policy ApprovalPolicy) (bool, []string, error) {
 
 	// Init gitlab client
 	git, err := gitlab.NewClient(c.Token, gitlab.WithBaseURL(c.URL))
 	if err != nil {
-		return false, fmt.Errorf("failed to init Gitlab client: %w", err)
+		return false, nil, fmt.Errorf("failed to init Gitlab client: %w", err)
 	}
 
-	// Get project and merge request IDs from Gitlab CI env vars
-	if os.Getenv("CI_PROJECT_ID") == "" || os.Getenv("CI_MERGE_REQUEST_IID") == "" {
-		return false, errors.New("CI_PROJECT_ID or CI_MERGE_REQUEST_IID env var is not defined, GOGCI must run in a Merge Request")
+	// Get project and merge request IDs
+	projectID, mrID, err := c.mergeRequest(git)
+	if err != nil {
+		return false, nil, err
 	}
-	projectID, err := strconv.Atoi(os.Getenv("CI_PROJECT_ID"))
+
+	// Get merge request approval state
+	approvalState, _, err := git.MergeRequestApprovals.GetApprovalState(projectID, mrID)
 	if err != nil {
-		return false, fmt.Errorf("failed to parse CI_PROJECT_ID env var: %w", err)
+		return false, nil, fmt.Errorf("failed to get merge request approval state: %w", err)
 	}
-	mrID, err := strconv.Atoi(os.Getenv("CI_MERGE_REQUEST_IID"))
+
+	// Get merge request approvers
+	approvals, _, err := git.MergeRequestApprovals.GetConfiguration(projectID, mrID)
 	if err != nil {
-		return false, fmt.Errorf("failed to parse CI_MERGE_REQUEST_IID env var: %w", err)
+		return false, nil, fmt.Errorf("failed to get merge request approvals: %w", err)
+	}
+	approvers := []*gitlab.BasicUser{}
+	for _, approver := range approvals.ApprovedBy {
+		if approver.User != nil {
+			approvers = append(approvers, approver.User)
+		}
 	}
 
-	// Get merge request approval state
-	approvalState, _, err := git.MergeRequestApprovals.GetApprovalState(projectID, mrID)
+	// Find approvals that must be ignored
+	ignored, err := c.ignoredApprovals(git, projectID, mrID, approvers, policy)
 	if err != nil {
-		return false, fmt.Errorf("failed to get merge request approval state: %w", err)
+		return false, nil, err
 	}
 
-	// Return true if all approval rules are ok
-	return c.CheckApprovalRules(approvalState), nil
+	// Check approval rules and policy requirements
+	reasons := []string{}
+	if !policy.groupsOnly {
+		reasons = c.CheckApprovalRules(approvalState, policy, ignored)
+	}
+
+	for _, group := range policy.Groups {
+		approved, err := c.groupApproved(git, group, approvers, ignored)
+		if err != nil {
+			return false, nil, err
+		}
+		if !approved {
+			reasons = append(reasons, fmt.Sprintf("no valid approval from a member of group `%s`", group))
+		}
+	}
+
+	protectedGroups := []string{}
+	for group := range policy.ProtectedGroups {
+		protectedGroups = append(protectedGroups, group)
+	}
+	sort.Strings(protectedGroups)
+
+	for _, group := range protectedGroups {
+		approved, err := c.groupApproved(git, group, approvers, ignored)
+		if err != nil {
+			return false, nil, err
+		}
+		if !approved {
+			reasons = append(reasons, fmt.Sprintf("no valid approval from a member of group `%s`, required by changes on `%s`", group, strings.Join(policy.ProtectedGroups[group], "`, `")))
+		}
+	}
+
+	for _, user := range policy.Users {
+		approved := false
+		for _, approver := range approvers {
+			if _, ok := ignored[approver.Username]; !ok && approver.Username == user {
+				approved = true
+			}
+		}
+		if !approved {
+			reasons = append(reasons, fmt.Sprintf("no valid approval from user `%s`", user))
+		}
+	}
+
+	if len(reasons) == 0 {
+		return true, nil, nil
+	}
+
+	// Explain ignored approvals
+	for _, approver := range approvers {
+		if reason, ok := ignored[approver.Username]; ok {
+			reasons = append(reasons, fmt.Sprintf("approval from `%s` ignored: %s", approver.Username, reason))
+		}
+	}
+
+	return false, reasons, nil
 }
 
-func (c *Client) CheckApprovalRules(approval *gitlab.MergeRequestApprovalState) bool {
+// CheckApprovalRules returns the list of unmet approval rules, approvals
+// listed in ignored are not counted
+func (c *Client) CheckApprovalRules(approval *gitlab.MergeRequestApprovalState, policy ApprovalPolicy, ignored map[string]string) []string {
+
+	reasons := []string{}
 
-	// For each rule check if number of approvals is less thant required
+	// Index rules by name
+	rules := map[string]*gitlab.MergeRequestApprovalRule{}
 	for _, rule := range approval.Rules {
-		if rule.ApprovalsRequired > len(rule.ApprovedBy) {
-			return false
+		rules[rule.Name] = rule
+	}
+
+	// Check that required rules exist
+	required := map[string]bool{}
+	for _, name := range policy.Rules {
+		required[name] = true
+		if _, ok := rules[name]; !ok {
+			reasons = append(reasons, fmt.Sprintf("required approval rule `%s` is not defined on the merge request", name))
+		}
+	}
+
+	// For each rule check if number of valid approvals is less than required
+	for _, rule := range approval.Rules {
+		approvals := 0
+		for _, approver := range rule.ApprovedBy {
+			if _, ok := ignored[approver.Username]; !ok {
+				approvals++
+			}
+		}
+
+		// Rules required by policy need at least one approval
+		needed := rule.ApprovalsRequired
+		if required[rule.Name] && needed < 1 {
+			needed = 1
+		}
+
+		if needed > approvals {
+			reasons = append(reasons, fmt.Sprintf("approval rule `%s` has %d valid approval(s) out of %d required", rule.Name, approvals, needed))
+		}
+	}
+
+	return reasons
+}
+
+// ignoredApprovals returns approvers whose approval doesn't count with the reason
+func (c *Client) ignoredApprovals(git *gitlab.Client, projectID, mrID int, approvers []*gitlab.BasicUser, policy ApprovalPolicy) (map[string]string, error) {
+
+	ignored := map[string]string{}
+
+	if !policy.ExcludeAuthor && !policy.ExcludeCommitters && !policy.AfterLastCommit && !policy.SamePlan {
+		return ignored, nil
+	}
+
+	// Exclude merge request author
+	if policy.ExcludeAuthor {
+		mr, _, err := git.MergeRequests.GetMergeRequest(projectID, mrID, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get merge request: %w", err)
+		}
+		if mr.Author != nil {
+			ignored[mr.Author.Username] = "merge request author"
+		}
+	}
+
+	if !policy.ExcludeCommitters && !policy.AfterLastCommit && !policy.SamePlan {
+		return ignored, nil
+	}
+
+	// Exclude committers, matched by commit email or by the user owning it
+	if policy.ExcludeCommitters {
+		commits, err := c.listMergeRequestCommits(git, projectID, mrID)
+		if err != nil {
+			return nil, err
+		}
+
+		emails := map[string]bool{}
+		for _, commit := range commits {
+			for _, email := range []string{commit.AuthorEmail, commit.CommitterEmail} {
+				if email != "" {
+					emails[strings.ToLower(email)] = true
+				}
+			}
+		}
+
+		// Resolve users owning commit emails, private emails are only
+		// searchable by administrators
+		committers := map[int]bool{}
+		for email := range emails {
+			users, _, err := git.Users.ListUsers(&gitlab.ListUsersOptions{Search: gitlab.String(email)})
+			if err != nil {
+				return nil, fmt.Errorf("failed to search user of email %q: %w", email, err)
+			}
+			for _, user := range users {
+				if strings.EqualFold(user.Email, email) || strings.EqualFold(user.PublicEmail, email) {
+					committers[user.ID] = true
+				}
+			}
+		}
+
+		for _, approver := range approvers {
+			if _, ok := ignored[approver.Username]; ok {
+				continue
+			}
+			if committers[approver.ID] {
+				ignored[approver.Username] = "merge request committer"
+				continue
+			}
+
+			user, _, err := git.Users.GetUser(approver.ID, gitlab.GetUsersOptions{})
+			if err != nil {
+				return nil, fmt.Errorf("failed to get user %q: %w", approver.Username, err)
+			}
+
+			for _, email := range []string{user.Email, user.PublicEmail} {
+				if email != "" && emails[strings.ToLower(email)] {
+					ignored[approver.Username] = "merge request committer"
+				}
+			}
+		}
+	}
+
+	if !policy.AfterLastCommit && !policy.SamePlan {
+		return ignored, nil
+	}
+
+	notes, err := c.listMergeRequestNotes(git, projectID, mrID)
+	if err != nil {
+		return nil, err
+	}
+	approvedAt := approvalDates(notes)
+
+	// Diff versions record server side push times, commit dates are set by clients
+	versions, err := c.listMergeRequestDiffVersions(git, projectID, mrID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Exclude approvals given before the latest push
+	if policy.AfterLastCommit {
+		var lastPush time.Time
+		for _, version := range versions {
+			if version.CreatedAt != nil && version.CreatedAt.After(lastPush) {
+				lastPush = *version.CreatedAt
+			}
+		}
+
+		for _, approver := range approvers {
+			if _, ok := ignored[approver.Username]; ok {
+				continue
+			}
+
+			date, ok := approvedAt[approver.Username]
+			if !ok || !date.After(lastPush) {
+				ignored[approver.Username] = "approved before the latest commit"
+			}
+		}
+	}
+
+	// Exclude approvals given on a plan that changed since
+	if policy.SamePlan {
+		dir, err := projectDir()
+		if err != nil {
+			return nil, err
+		}
+
+		// Only plans recorded by gogci are trusted
+		own, err := c.ownNotes(git, notes)
+		if err != nil {
+			return nil, err
+		}
+
+		records := planRecords(own, dir, c.Workspace)
+		current, ok := records[c.headCommit()]
+
+		for _, approver := range approvers {
+			if _, ok := ignored[approver.Username]; ok {
+				continue
+			}
+
+			// Without a plan for the current commit nothing can be compared
+			if !ok {
+				ignored[approver.Username] = fmt.Sprintf("no plan recorded for commit `%s` in dir `%s`", shortSHA(c.headCommit()), dir)
+				continue
+			}
+
+			date, found := approvedAt[approver.Username]
+			if !found {
+				ignored[approver.Username] = "approval date not found"
+				continue
+			}
+
+			// Find the head commit at approval time
+			approvedSHA := headAt(versions, date)
+
+			if approvedSHA == current.Commit {
+				continue
+			}
+
+			approved, found := records[approvedSHA]
+			if !found || approved.Fingerprint != current.Fingerprint {
+				ignored[approver.Username] = fmt.Sprintf("plan in dir `%s` changed since approval of commit `%s`, approval must be renewed", dir, shortSHA(approvedSHA))
+			}
+		}
+	}
+
+	return ignored, nil
+}
+
+// groupApproved checks if a valid approver is a member of the group
+func (c *Client) groupApproved(git *gitlab.Client, group string, approvers []*gitlab.BasicUser, ignored map[string]string) (bool, error) {
+
+	ids := []int{}
+	for _, approver := range approvers {
+		if _, ok := ignored[approver.Username]; !ok {
+			ids = append(ids, approver.ID)
+		}
+	}
+	if len(ids) == 0 {
+		return false, nil
+	}
+
+	// List group members, including inherited ones, among approvers
+	members, _, err := git.Groups.ListAllGroupMembers(group, &gitlab.ListGroupMembersOptions{UserIDs: &ids})
+	if err != nil {
+		return false, fmt.Errorf("failed to list members of group %q: %w", group, err)
+	}
+
+	return len(members) > 0, nil
+}
+
+// listMergeRequestCommits returns all commits of a merge request
+func (c *Client) listMergeRequestCommits(git *gitlab.Client, projectID, mrID int) ([]*gitlab.Commit, error) {
+
+	commits := []*gitlab.Commit{}
+	opt := &gitlab.GetMergeRequestCommitsOptions{PerPage: 100, Page: 1}
+	for {
+		page, resp, err := git.MergeRequests.GetMergeRequestCommits(projectID, mrID, opt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list merge request commits: %w", err)
+		}
+		commits = append(commits, page...)
+
+		if resp.NextPage == 0 {
+			break
+		}
+		opt.Page = resp.NextPage
+	}
+
+	return commits, nil
+}
+
+// listMergeRequestDiffVersions returns all diff versions of a merge request,
+// one per push
+func (c *Client) listMergeRequestDiffVersions(git *gitlab.Client, projectID, mrID int) ([]*gitlab.MergeRequestDiffVersion, error) {
+
+	versions := []*gitlab.MergeRequestDiffVersion{}
+	opt := &gitlab.GetMergeRequestDiffVersionsOptions{PerPage: 100, Page: 1}
+	for {
+		page, resp, err := git.MergeRequests.GetMergeRequestDiffVersions(projectID, mrID, opt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list merge request diff versions: %w", err)
+		}
+		versions = append(versions, page...)
+
+		if resp.NextPage == 0 {
+			break
+		}
+		opt.Page = resp.NextPage
+	}
+
+	return versions, nil
+}
+
+// headAt returns the merge request head commit at date, pushed by the latest
+// diff version created before date
+func headAt(versions []*gitlab.MergeRequestDiffVersion, date time.Time) string {
+
+	var sha string
+	var pushedAt time.Time
+	for _, version := range versions {
+		if version.CreatedAt == nil || version.CreatedAt.After(date) {
+			continue
+		}
+		if sha == "" || version.CreatedAt.After(pushedAt) {
+			sha = version.HeadCommitSHA
+			pushedAt = *version.CreatedAt
+		}
+	}
+
+	return sha
+}
+
+// approvalDates returns the date of the current approval of each approver,
+// extracted from merge request system notes
+func approvalDates(notes []*gitlab.Note) map[string]time.Time {
+
+	dates := map[string]time.Time{}
+	for _, note := range notes {
+		if !note.System || note.CreatedAt == nil {
+			continue
+		}
+
+		switch note.Body {
+		case "approved this merge request":
+			dates[note.Author.Username] = *note.CreatedAt
+		case "unapproved this merge request":
+			delete(dates, note.Author.Username)
+		}
+	}
+
+	return dates
+}
+
+// shortSHA returns the abbreviated form of a commit SHA
+func shortSHA(sha string) string {
+	if len(sha) > 8 {
+		return sha[:8]
+	}
+	if sha == "" {
+		return "unknown"
+	}
+	return sha
+}
+
+// listMergeRequestNotes returns all notes of a merge request, oldest first
+func (c *Client) listMergeRequestNotes(git *gitlab.Client, projectID, mrID int) ([]*gitlab.Note, error) {
+
+	notes := []*gitlab.Note{}
+	opt := &gitlab.ListMergeRequestNotesOptions{
+		ListOptions: gitlab.ListOptions{PerPage: 100, Page: 1},
+		OrderBy:     gitlab.String("created_at"),
+		Sort:        gitlab.String("asc"),
+	}
+	for {
+		page, resp, err := git.Notes.ListMergeRequestNotes(projectID, mrID, opt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list merge request notes: %w", err)
+		}
+		notes = append(notes, page...)
+
+		if resp.NextPage == 0 {
+			break
 		}
+		opt.Page = resp.NextPage
 	}
 
-	return true
+	return notes, nil
 }
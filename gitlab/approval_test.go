@@ -0,0 +1,276 @@
+package gitlab
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/xanzy/go-gitlab"
+)
+
+func users(usernames ...string) []*gitlab.BasicUser {
+	list := []*gitlab.BasicUser{}
+	for _, username := range usernames {
+		list = append(list, &gitlab.BasicUser{Username: username})
+	}
+	return list
+}
+
+func at(hour int) *time.Time {
+	t := time.Date(2022, 6, 1, hour, 0, 0, 0, time.UTC)
+	return &t
+}
+
+func TestCheckApprovalRules(t *testing.T) {
+
+	approval := &gitlab.MergeRequestApprovalState{
+		Rules: []*gitlab.MergeRequestApprovalRule{
+			{Name: "All Members", ApprovalsRequired: 1, ApprovedBy: users("alice")},
+			{Name: "Ops", ApprovalsRequired: 2, ApprovedBy: users("bob", "carol")},
+			{Name: "Security", ApprovalsRequired: 0},
+		},
+	}
+
+	tests := []struct {
+		name    string
+		policy  ApprovalPolicy
+		ignored map[string]string
+		want    []string
+	}{
+		{
+			name: "rules approved",
+			want: []string{},
+		},
+		{
+			name:    "ignored approval is not counted",
+			ignored: map[string]string{"carol": "committer"},
+			want:    []string{"approval rule `Ops` has 1 valid approval(s) out of 2 required"},
+		},
+		{
+			name:   "required optional rule needs one approval",
+			policy: ApprovalPolicy{Rules: []string{"Security"}},
+			want:   []string{"approval rule `Security` has 0 valid approval(s) out of 1 required"},
+		},
+		{
+			name:   "required rule not defined",
+			policy: ApprovalPolicy{Rules: []string{"DBA", "All Members"}},
+			want:   []string{"required approval rule `DBA` is not defined on the merge request"},
+		},
+		{
+			name:    "every unmet rule is reported",
+			policy:  ApprovalPolicy{Rules: []string{"DBA"}},
+			ignored: map[string]string{"alice": "author", "bob": "committer"},
+			want: []string{
+				"required approval rule `DBA` is not defined on the merge request",
+				"approval rule `All Members` has 0 valid approval(s) out of 1 required",
+				"approval rule `Ops` has 1 valid approval(s) out of 2 required",
+			},
+		},
+	}
+
+	c := &Client{}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := c.CheckApprovalRules(approval, tt.policy, tt.ignored)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("CheckApprovalRules() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApprovalDates(t *testing.T) {
+
+	note := func(username, body string, system bool, date *time.Time) *gitlab.Note {
+		n := &gitlab.Note{Body: body, System: system, CreatedAt: date}
+		n.Author.Username = username
+		return n
+	}
+
+	tests := []struct {
+		name  string
+		notes []*gitlab.Note
+		want  map[string]time.Time
+	}{
+		{
+			name:  "no notes",
+			notes: []*gitlab.Note{},
+			want:  map[string]time.Time{},
+		},
+		{
+			name: "approvals",
+			notes: []*gitlab.Note{
+				note("alice", "approved this merge request", true, at(1)),
+				note("bob", "approved this merge request", true, at(2)),
+			},
+			want: map[string]time.Time{"alice": *at(1), "bob": *at(2)},
+		},
+		{
+			name: "latest approval counts",
+			notes: []*gitlab.Note{
+				note("alice", "approved this merge request", true, at(1)),
+				note("alice", "unapproved this merge request", true, at(2)),
+				note("alice", "approved this merge request", true, at(3)),
+			},
+			want: map[string]time.Time{"alice": *at(3)},
+		},
+		{
+			name: "revoked approval",
+			notes: []*gitlab.Note{
+				note("alice", "approved this merge request", true, at(1)),
+				note("alice", "unapproved this merge request", true, at(2)),
+			},
+			want: map[string]time.Time{},
+		},
+		{
+			name: "user comments are ignored",
+			notes: []*gitlab.Note{
+				note("mallory", "approved this merge request", false, at(1)),
+				note("bob", "approved this merge request", true, nil),
+			},
+			want: map[string]time.Time{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := approvalDates(tt.notes)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("approvalDates() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHeadAt(t *testing.T) {
+
+	// Versions are listed newest first by Gitlab
+	versions := []*gitlab.MergeRequestDiffVersion{
+		{HeadCommitSHA: "ccc", CreatedAt: at(5)},
+		{HeadCommitSHA: "bbb", CreatedAt: at(3)},
+		{HeadCommitSHA: "aaa", CreatedAt: at(1)},
+	}
+
+	tests := []struct {
+		name     string
+		versions []*gitlab.MergeRequestDiffVersion
+		date     time.Time
+		want     string
+	}{
+		{
+			name:     "before first push",
+			versions: versions,
+			date:     *at(0),
+			want:     "",
+		},
+		{
+			name:     "at a push",
+			versions: versions,
+			date:     *at(3),
+			want:     "bbb",
+		},
+		{
+			name:     "between pushes",
+			versions: versions,
+			date:     *at(4),
+			want:     "bbb",
+		},
+		{
+			name:     "after last push",
+			versions: versions,
+			date:     *at(6),
+			want:     "ccc",
+		},
+		{
+			name: "versions without date are ignored",
+			versions: []*gitlab.MergeRequestDiffVersion{
+				{HeadCommitSHA: "ccc"},
+				{HeadCommitSHA: "aaa", CreatedAt: at(1)},
+			},
+			date: *at(6),
+			want: "aaa",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := headAt(tt.versions, tt.date); got != tt.want {
+				t.Errorf("headAt() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckMergeRequestApprovedGroupsOnly(t *testing.T) {
+
+	// Fake Gitlab with an unmet approval rule and an approval from user 7,
+	// member of group ops only
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v4/projects/1/merge_requests/2/approval_state":
+			fmt.Fprint(w, `{"rules": [{"name": "All Members", "approvals_required": 2, "approved_by": [{"id": 7, "username": "alice"}]}]}`)
+		case "/api/v4/projects/1/merge_requests/2/approvals":
+			fmt.Fprint(w, `{"approved_by": [{"user": {"id": 7, "username": "alice"}}]}`)
+		case "/api/v4/groups/ops/members/all":
+			fmt.Fprint(w, `[{"id": 7, "username": "alice"}]`)
+		case "/api/v4/groups/dba/members/all":
+			fmt.Fprint(w, `[]`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	os.Setenv("CI_PROJECT_ID", "1")
+	os.Setenv("CI_MERGE_REQUEST_IID", "2")
+	defer os.Unsetenv("CI_PROJECT_ID")
+	defer os.Unsetenv("CI_MERGE_REQUEST_IID")
+
+	tests := []struct {
+		name    string
+		policy  ApprovalPolicy
+		want    bool
+		reasons []string
+	}{
+		{
+			name:   "group member approval",
+			policy: ApprovalPolicy{Groups: []string{"ops"}}.GroupsOnly(),
+			want:   true,
+		},
+		{
+			name:    "no group member approval",
+			policy:  ApprovalPolicy{Groups: []string{"dba"}}.GroupsOnly(),
+			reasons: []string{"no valid approval from a member of group `dba`"},
+		},
+		{
+			name:   "rules and users are not checked",
+			policy: ApprovalPolicy{Groups: []string{"ops"}, Rules: []string{"Security"}, Users: []string{"bob"}}.GroupsOnly(),
+			want:   true,
+		},
+		{
+			name:    "approval rules are checked otherwise",
+			policy:  ApprovalPolicy{Groups: []string{"ops"}},
+			reasons: []string{"approval rule `All Members` has 1 valid approval(s) out of 2 required"},
+		},
+	}
+
+	c := &Client{URL: server.URL}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, reasons, err := c.CheckMergeRequestApproved(tt.policy)
+			if err != nil {
+				t.Fatalf("CheckMergeRequestApproved() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("CheckMergeRequestApproved() = %v, want %v", got, tt.want)
+			}
+			if !reflect.DeepEqual(reasons, tt.reasons) {
+				t.Errorf("CheckMergeRequestApproved() reasons = %q, want %q", reasons, tt.reasons)
+			}
+		})
+	}
+}
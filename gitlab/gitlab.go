@@ -1,6 +1,148 @@
 package gitlab
 
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/xanzy/go-gitlab"
+)
+
 type Client struct {
 	Token string
 	URL   string
+
+	// Tool and version shown in summary comments
+	Tool string
+
+	// Terraform workspace shown in comments and used as plan record key
+	Workspace string
+
+	// Set commit statuses in addition to merge request comments
+	CommitStatus bool
+
+	// Manage merge request labels reflecting plan outcome
+	Labels bool
+
+	// Merge request resolved from the commit SHA in branch pipelines
+	merged *gitlab.MergeRequest
+
+	// Latest commit status state set by stage
+	statuses map[string]string
+
+	// ID of the token user, the only author of trusted gogci markers
+	userID int
+}
+
+// PostMerge returns true when running in a branch pipeline, after the merge
+// request has been merged
+func (c *Client) PostMerge() bool {
+	return os.Getenv("CI_MERGE_REQUEST_IID") == ""
+}
+
+// mergeRequest returns project and merge request IDs from Gitlab CI env vars,
+// in branch pipelines the merge request is the one merged by the commit
+func (c *Client) mergeRequest(git *gitlab.Client) (int, int, error) {
+
+	// Get project ID from Gitlab CI env vars
+	if os.Getenv("CI_PROJECT_ID") == "" {
+		return 0, 0, errors.New("CI_PROJECT_ID env var is not defined, GOGCI must run in a Gitlab CI pipeline")
+	}
+	projectID, err := strconv.Atoi(os.Getenv("CI_PROJECT_ID"))
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to parse CI_PROJECT_ID env var: %w", err)
+	}
+
+	// Get merge request ID from merge request pipeline env vars
+	if !c.PostMerge() {
+		mrID, err := strconv.Atoi(os.Getenv("CI_MERGE_REQUEST_IID"))
+		if err != nil {
+			return 0, 0, fmt.Errorf("failed to parse CI_MERGE_REQUEST_IID env var: %w", err)
+		}
+
+		return projectID, mrID, nil
+	}
+
+	// Resolve merged merge request from commit SHA
+	if c.merged == nil {
+		mr, err := c.mergedMergeRequest(git, projectID)
+		if err != nil {
+			return 0, 0, err
+		}
+		c.merged = mr
+	}
+
+	return projectID, c.merged.IID, nil
+}
+
+// mergedMergeRequest returns the merge request merged by the pipeline commit
+func (c *Client) mergedMergeRequest(git *gitlab.Client, projectID int) (*gitlab.MergeRequest, error) {
+
+	sha := os.Getenv("CI_COMMIT_SHA")
+	if sha == "" {
+		return nil, errors.New("CI_MERGE_REQUEST_IID and CI_COMMIT_SHA env vars are not defined, GOGCI must run in a Merge Request or branch pipeline")
+	}
+
+	// Only pushes to a branch follow a merge, not tags or scheduled pipelines
+	branch := os.Getenv("CI_COMMIT_BRANCH")
+	if branch == "" || os.Getenv("CI_PIPELINE_SOURCE") == "schedule" {
+		return nil, fmt.Errorf("no merge request merged by commit %s, GOGCI must run in a Merge Request or target branch pipeline", sha)
+	}
+
+	// List merge requests related to commit
+	mrs, _, err := git.Commits.ListMergeRequestsByCommit(projectID, sha)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list merge requests of commit %s: %w", sha, err)
+	}
+
+	// Only the merge request merged by this exact commit into the pipeline
+	// branch is about the pipeline, other merged merge requests merely
+	// contain the commit
+	for _, mr := range mrs {
+		if mr.State != "merged" || mr.TargetBranch != branch {
+			continue
+		}
+		if mr.MergeCommitSHA == sha || mr.SquashCommitSHA == sha || mr.SHA == sha {
+			return mr, nil
+		}
+	}
+
+	return nil, fmt.Errorf("no merge request merged by commit %s into branch %s", sha, branch)
+}
+
+// headCommit returns the merge request commit the pipeline is about, the
+// merge request head in branch pipelines. Merged results pipelines run on a
+// temporary merge commit, their merge request head is the source branch one.
+func (c *Client) headCommit() string {
+	if c.PostMerge() && c.merged != nil {
+		return c.merged.SHA
+	}
+	if sha := os.Getenv("CI_MERGE_REQUEST_SOURCE_BRANCH_SHA"); sha != "" {
+		return sha
+	}
+	return os.Getenv("CI_COMMIT_SHA")
+}
+
+// ownNotes returns the non-system notes written by the token user, gogci
+// markers of other notes may be forged by any participant
+func (c *Client) ownNotes(git *gitlab.Client, notes []*gitlab.Note) ([]*gitlab.Note, error) {
+
+	// Resolve token user once
+	if c.userID == 0 {
+		user, _, err := git.Users.CurrentUser()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get Gitlab token user: %w", err)
+		}
+		c.userID = user.ID
+	}
+
+	own := []*gitlab.Note{}
+	for _, note := range notes {
+		if !note.System && note.Author.ID == c.userID {
+			own = append(own, note)
+		}
+	}
+
+	return own, nil
 }
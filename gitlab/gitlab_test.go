@@ -0,0 +1,132 @@
+package gitlab
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/xanzy/go-gitlab"
+)
+
+func TestMergedMergeRequest(t *testing.T) {
+
+	// Fake Gitlab listing merge requests of commit abc: merged by it into
+	// main, merged by it into release, and merged earlier with it
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v4/projects/1/repository/commits/abc/merge_requests" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, `[
+			{"iid": 3, "state": "merged", "target_branch": "main", "sha": "old", "merge_commit_sha": "def"},
+			{"iid": 4, "state": "merged", "target_branch": "release", "sha": "xyz", "merge_commit_sha": "abc"},
+			{"iid": 5, "state": "merged", "target_branch": "main", "sha": "xyz", "merge_commit_sha": "abc"},
+			{"iid": 6, "state": "opened", "target_branch": "main", "sha": "abc"}
+		]`)
+	}))
+	defer server.Close()
+
+	git, err := gitlab.NewClient("", gitlab.WithBaseURL(server.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		env     map[string]string
+		want    int
+		wantErr bool
+	}{
+		{
+			name: "target branch pipeline",
+			env:  map[string]string{"CI_COMMIT_SHA": "abc", "CI_COMMIT_BRANCH": "main", "CI_PIPELINE_SOURCE": "push"},
+			want: 5,
+		},
+		{
+			name: "other target branch pipeline",
+			env:  map[string]string{"CI_COMMIT_SHA": "abc", "CI_COMMIT_BRANCH": "release", "CI_PIPELINE_SOURCE": "push"},
+			want: 4,
+		},
+		{
+			name:    "branch without merge request merged into it",
+			env:     map[string]string{"CI_COMMIT_SHA": "abc", "CI_COMMIT_BRANCH": "feature", "CI_PIPELINE_SOURCE": "push"},
+			wantErr: true,
+		},
+		{
+			name:    "tag pipeline",
+			env:     map[string]string{"CI_COMMIT_SHA": "abc", "CI_COMMIT_TAG": "v1.0.0", "CI_PIPELINE_SOURCE": "push"},
+			wantErr: true,
+		},
+		{
+			name:    "scheduled pipeline",
+			env:     map[string]string{"CI_COMMIT_SHA": "abc", "CI_COMMIT_BRANCH": "main", "CI_PIPELINE_SOURCE": "schedule"},
+			wantErr: true,
+		},
+		{
+			name:    "no commit",
+			env:     map[string]string{"CI_COMMIT_BRANCH": "main"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, name := range []string{"CI_COMMIT_SHA", "CI_COMMIT_BRANCH", "CI_COMMIT_TAG", "CI_PIPELINE_SOURCE"} {
+				os.Setenv(name, tt.env[name])
+				defer os.Unsetenv(name)
+			}
+
+			c := &Client{}
+			mr, err := c.mergedMergeRequest(git, 1)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("mergedMergeRequest() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && mr.IID != tt.want {
+				t.Errorf("mergedMergeRequest() = !%d, want !%d", mr.IID, tt.want)
+			}
+		})
+	}
+}
+
+func TestHeadCommit(t *testing.T) {
+
+	tests := []struct {
+		name   string
+		env    map[string]string
+		merged *gitlab.MergeRequest
+		want   string
+	}{
+		{
+			name: "merge request pipeline",
+			env:  map[string]string{"CI_MERGE_REQUEST_IID": "2", "CI_COMMIT_SHA": "abc"},
+			want: "abc",
+		},
+		{
+			name: "merged results pipeline",
+			env:  map[string]string{"CI_MERGE_REQUEST_IID": "2", "CI_COMMIT_SHA": "tmp", "CI_MERGE_REQUEST_SOURCE_BRANCH_SHA": "abc"},
+			want: "abc",
+		},
+		{
+			name:   "target branch pipeline",
+			env:    map[string]string{"CI_COMMIT_SHA": "def"},
+			merged: &gitlab.MergeRequest{SHA: "abc", MergeCommitSHA: "def"},
+			want:   "abc",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, name := range []string{"CI_MERGE_REQUEST_IID", "CI_COMMIT_SHA", "CI_MERGE_REQUEST_SOURCE_BRANCH_SHA"} {
+				os.Setenv(name, tt.env[name])
+				defer os.Unsetenv(name)
+			}
+
+			c := &Client{merged: tt.merged}
+			if got := c.headCommit(); got != tt.want {
+				t.Errorf("headCommit() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,134 @@
+package gitlab
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/Ouest-France/gogci/terraform"
+	"github.com/xanzy/go-gitlab"
+)
+
+// Plan outcome labels
+const (
+	LabelNoChanges = "tf::no-changes"
+	LabelChanges   = "tf::changes"
+	LabelDestroys  = "tf::destroys"
+	LabelFailed    = "tf::failed"
+)
+
+// labelSeverity orders outcome labels, the most severe one is set on the
+// merge request
+var labelSeverity = map[string]int{
+	LabelNoChanges: 0,
+	LabelChanges:   1,
+	LabelDestroys:  2,
+	LabelFailed:    3,
+}
+
+// outcomeMarkerRegexp matches the hidden marker recording a plan outcome
+var outcomeMarkerRegexp = regexp.MustCompile(`<!-- gogci:outcome dir="([^"]*)" workspace="([^"]*)" commit="([^"]*)" label="([^"]*)" -->`)
+
+// outcomeMarker returns the hidden marker recording the outcome label of a
+// plan in dir and workspace
+func outcomeMarker(dir, workspace, commit, label string) string {
+	return fmt.Sprintf(`<!-- gogci:outcome dir="%s" workspace="%s" commit="%s" label="%s" -->`, dir, workspace, commit, label)
+}
+
+// planOutcomes returns the latest outcome label recorded for commit in each
+// dir and workspace, notes must be the ones written by the token user
+func planOutcomes(notes []*gitlab.Note, commit string) map[[2]string]string {
+
+	outcomes := map[[2]string]string{}
+	for _, note := range notes {
+		for _, match := range outcomeMarkerRegexp.FindAllStringSubmatch(note.Body, -1) {
+			if match[3] == commit {
+				outcomes[[2]string{match[1], match[2]}] = match[4]
+			}
+		}
+	}
+
+	return outcomes
+}
+
+// WorstLabel returns the most severe of two plan outcome labels
+func WorstLabel(a, b string) string {
+	if labelSeverity[b] > labelSeverity[a] {
+		return b
+	}
+	return a
+}
+
+// PlanLabel returns the outcome label matching a plan summary
+func PlanLabel(summary terraform.PlanSummary) string {
+	switch {
+	case !summary.Found:
+		return LabelFailed
+	case summary.NoChanges:
+		return LabelNoChanges
+	case summary.Destroy > 0:
+		return LabelDestroys
+	default:
+		return LabelChanges
+	}
+}
+
+// SetPlanLabel adds the most severe outcome label among label, the outcome of
+// the current dir and workspace, and outcomes recorded by other dirs and
+// workspaces for the head commit. Stale labels are removed. It does nothing
+// unless labels are enabled on the client.
+func (c *Client) SetPlanLabel(label string) error {
+
+	if !c.Labels {
+		return nil
+	}
+
+	// Init gitlab client
+	git, err := gitlab.NewClient(c.Token, gitlab.WithBaseURL(c.URL))
+	if err != nil {
+		return fmt.Errorf("failed to init gitlab client: %w", err)
+	}
+
+	// Get project and merge request IDs
+	projectID, mrID, err := c.mergeRequest(git)
+	if err != nil {
+		return err
+	}
+
+	// Keep the worst outcome of plans in other dirs and workspaces
+	wd, err := projectDir()
+	if err != nil {
+		return err
+	}
+	notes, err := c.listMergeRequestNotes(git, projectID, mrID)
+	if err != nil {
+		return err
+	}
+	notes, err = c.ownNotes(git, notes)
+	if err != nil {
+		return err
+	}
+	for key, outcome := range planOutcomes(notes, c.headCommit()) {
+		if key != [2]string{wd, c.Workspace} {
+			label = WorstLabel(label, outcome)
+		}
+	}
+
+	// Remove other outcome labels
+	stale := gitlab.Labels{}
+	for _, l := range []string{LabelNoChanges, LabelChanges, LabelDestroys, LabelFailed} {
+		if l != label {
+			stale = append(stale, l)
+		}
+	}
+
+	// Update merge request labels
+	_, _, err = git.MergeRequests.UpdateMergeRequest(projectID, mrID, &gitlab.UpdateMergeRequestOptions{
+		AddLabels:    &gitlab.Labels{label},
+		RemoveLabels: &stale,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update merge request labels: %w", err)
+	}
+
+	return nil
+}
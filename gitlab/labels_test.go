@@ -0,0 +1,124 @@
+package gitlab
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/Ouest-France/gogci/terraform"
+	"github.com/xanzy/go-gitlab"
+)
+
+func TestPlanLabel(t *testing.T) {
+
+	tests := []struct {
+		name    string
+		summary terraform.PlanSummary
+		want    string
+	}{
+		{"summary not found", terraform.PlanSummary{}, LabelFailed},
+		{"no changes", terraform.PlanSummary{Found: true, NoChanges: true}, LabelNoChanges},
+		{"changes", terraform.PlanSummary{Found: true, Add: 2, Change: 1}, LabelChanges},
+		{"destroys", terraform.PlanSummary{Found: true, Add: 1, Destroy: 1}, LabelDestroys},
+		{"replacements", terraform.PlanSummary{Found: true, Add: 3, Destroy: 3}, LabelDestroys},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := PlanLabel(tt.summary); got != tt.want {
+				t.Errorf("PlanLabel() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWorstLabel(t *testing.T) {
+
+	tests := []struct {
+		a, b string
+		want string
+	}{
+		{LabelNoChanges, LabelNoChanges, LabelNoChanges},
+		{LabelNoChanges, LabelChanges, LabelChanges},
+		{LabelChanges, LabelNoChanges, LabelChanges},
+		{LabelDestroys, LabelNoChanges, LabelDestroys},
+		{LabelChanges, LabelDestroys, LabelDestroys},
+		{LabelDestroys, LabelFailed, LabelFailed},
+		{LabelFailed, LabelChanges, LabelFailed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.a+" "+tt.b, func(t *testing.T) {
+			if got := WorstLabel(tt.a, tt.b); got != tt.want {
+				t.Errorf("WorstLabel(%q, %q) = %q, want %q", tt.a, tt.b, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPlanOutcomes(t *testing.T) {
+
+	note := func(body string) *gitlab.Note {
+		return &gitlab.Note{Body: body}
+	}
+
+	tests := []struct {
+		name  string
+		notes []*gitlab.Note
+		want  map[[2]string]string
+	}{
+		{
+			name:  "no outcomes",
+			notes: []*gitlab.Note{note("Terraform plan running")},
+			want:  map[[2]string]string{},
+		},
+		{
+			name: "outcomes of each dir and workspace",
+			notes: []*gitlab.Note{
+				note(outcomeMarker("/network", "", "abc", LabelDestroys)),
+				note(outcomeMarker("/app", "prod", "abc", LabelChanges)),
+				note(outcomeMarker("/app", "staging", "abc", LabelNoChanges)),
+			},
+			want: map[[2]string]string{
+				{"/network", ""}:    LabelDestroys,
+				{"/app", "prod"}:    LabelChanges,
+				{"/app", "staging"}: LabelNoChanges,
+			},
+		},
+		{
+			name: "latest outcome counts",
+			notes: []*gitlab.Note{
+				note(outcomeMarker("/network", "", "abc", LabelFailed)),
+				note(outcomeMarker("/network", "", "abc", LabelNoChanges)),
+			},
+			want: map[[2]string]string{{"/network", ""}: LabelNoChanges},
+		},
+		{
+			name: "other commits are ignored",
+			notes: []*gitlab.Note{
+				note(outcomeMarker("/network", "", "old", LabelDestroys)),
+				note(outcomeMarker("/app", "", "abc", LabelChanges)),
+			},
+			want: map[[2]string]string{{"/app", ""}: LabelChanges},
+		},
+		{
+			name: "several outcomes in a note",
+			notes: []*gitlab.Note{
+				note(planMarker("/app", "prod", "abc", "f1") + "\n" + outcomeMarker("/app", "prod", "abc", LabelDestroys) + "\n" +
+					outcomeMarker("/app", "staging", "abc", LabelFailed)),
+			},
+			want: map[[2]string]string{
+				{"/app", "prod"}:    LabelDestroys,
+				{"/app", "staging"}: LabelFailed,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := planOutcomes(tt.notes, "abc")
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("planOutcomes() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,268 @@
+package gitlab
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/Ouest-France/gogci/terraform"
+	"github.com/xanzy/go-gitlab"
+)
+
+// runMarkerRegexp matches the hidden marker added to running comments
+var runMarkerRegexp = regexp.MustCompile(`<!-- gogci:run dir="([^"]*)"(?: workspace="([^"]*)")? pipeline="([0-9]+)"(?: who="([^"]*)")? -->`)
+
+// lockMarkerRegexp matches the hidden marker added to state lock comments
+var lockMarkerRegexp = regexp.MustCompile(`<!-- gogci:lock dir="([^"]*)"(?: workspace="([^"]*)")? id="([^"]*)" who="([^"]*)" created="([^"]*)" pipeline="([0-9]+)" -->`)
+
+// lockHolderWindow is the longest delay between a running comment and the
+// lock taken by its pipeline, clock skew included
+const lockHolderWindow = 5 * time.Minute
+
+// runMarker returns the hidden marker recording the pipeline running in dir,
+// with the identity terraform records in the locks it takes
+func runMarker(dir, workspace string) string {
+	if workspace == "" {
+		return fmt.Sprintf(`<!-- gogci:run dir="%s" pipeline="%s" who="%s" -->`, dir, os.Getenv("CI_PIPELINE_ID"), terraform.LockWho())
+	}
+	return fmt.Sprintf(`<!-- gogci:run dir="%s" workspace="%s" pipeline="%s" who="%s" -->`, dir, workspace, os.Getenv("CI_PIPELINE_ID"), terraform.LockWho())
+}
+
+// lockMarker returns the hidden marker recording a state lock met in dir by
+// the current pipeline
+func lockMarker(dir, workspace string, lock terraform.Lock) string {
+	if workspace == "" {
+		return fmt.Sprintf(`<!-- gogci:lock dir="%s" id="%s" who="%s" created="%s" pipeline="%s" -->`, dir, lock.ID, lock.Who, lock.Created, os.Getenv("CI_PIPELINE_ID"))
+	}
+	return fmt.Sprintf(`<!-- gogci:lock dir="%s" workspace="%s" id="%s" who="%s" created="%s" pipeline="%s" -->`, dir, workspace, lock.ID, lock.Who, lock.Created, os.Getenv("CI_PIPELINE_ID"))
+}
+
+// runningPipelineStatuses are the pipeline statuses of a pipeline not finished
+var runningPipelineStatuses = map[string]bool{
+	"created":              true,
+	"waiting_for_resource": true,
+	"preparing":            true,
+	"pending":              true,
+	"running":              true,
+	"scheduled":            true,
+}
+
+func (c *Client) TerraformStateLocked(stage string, lock terraform.Lock) error {
+
+	var notif = " :lock: Terraform {{.Stage}} **failed** on a state lock in dir `{{.Dir}}`{{if .Workspace}} for workspace `{{.Workspace}}`{{end}} for commit `{{.Commit}}` in pipeline `{{.PipelineID}}`." + `
+
+| Lock ID | Holder | Operation | Created |
+| ------- | ------ | --------- | ------- |
+| ` + "`{{.Lock.ID}}`" + ` | {{.Lock.Who}} | {{.Lock.Operation}} | {{.Lock.Created}} |
+
+Once the holder pipeline has finished, the lock can be released with ` + "`gogci tf unlock {{.Lock.ID}}`" + ` after the force-unlock label or approval is given.
+
+:memo: [see job log]({{.Job}}) | :arrow_forward: [see pipeline]({{.PipelineURL}})
+{{.Marker}}`
+
+	// Get working directory
+	wd, err := projectDir()
+	if err != nil {
+		return err
+	}
+
+	// A failed plan outcome is recorded for labels
+	marker := lockMarker(wd, c.Workspace, lock)
+	if stage == "plan" {
+		marker += "\n" + outcomeMarker(wd, c.Workspace, c.headCommit(), LabelFailed)
+	}
+
+	// Collect data for templating
+	data := struct {
+		Stage, Dir, Workspace, Commit, Job, PipelineID, PipelineURL, Marker string
+		Lock                                                                terraform.Lock
+	}{
+		Stage:       stage,
+		Dir:         wd,
+		Workspace:   c.Workspace,
+		Commit:      os.Getenv("CI_COMMIT_SHORT_SHA"),
+		Job:         os.Getenv("CI_JOB_URL"),
+		PipelineID:  os.Getenv("CI_PIPELINE_ID"),
+		PipelineURL: os.Getenv("CI_PIPELINE_URL"),
+		Lock:        lock,
+		Marker:      marker,
+	}
+
+	// Create comment
+	err = c.CreateMergeRequestNote(notif, data)
+	if err != nil {
+		return fmt.Errorf("failed to create merge request comment: %w", err)
+	}
+
+	return nil
+}
+
+func (c *Client) TerraformUnlocked(lockID string) error {
+
+	var notif = ":unlock: Terraform state lock `{{.LockID}}` force-unlocked in dir `{{.Dir}}`{{if .Workspace}} for workspace `{{.Workspace}}`{{end}} in pipeline `{{.PipelineID}}`." + `
+
+:memo: [see job log]({{.Job}}) | :arrow_forward: [see pipeline]({{.PipelineURL}})`
+
+	// Get working directory
+	wd, err := projectDir()
+	if err != nil {
+		return err
+	}
+
+	// Collect data for templating
+	data := struct {
+		LockID, Dir, Workspace, Job, PipelineID, PipelineURL string
+	}{
+		LockID:      lockID,
+		Dir:         wd,
+		Workspace:   c.Workspace,
+		Job:         os.Getenv("CI_JOB_URL"),
+		PipelineID:  os.Getenv("CI_PIPELINE_ID"),
+		PipelineURL: os.Getenv("CI_PIPELINE_URL"),
+	}
+
+	// Create comment
+	err = c.CreateMergeRequestNote(notif, data)
+	if err != nil {
+		return fmt.Errorf("failed to create merge request comment: %w", err)
+	}
+
+	return nil
+}
+
+// HasLabel returns true when the merge request has the label
+func (c *Client) HasLabel(label string) (bool, error) {
+
+	// Init gitlab client
+	git, err := gitlab.NewClient(c.Token, gitlab.WithBaseURL(c.URL))
+	if err != nil {
+		return false, fmt.Errorf("failed to init Gitlab client: %w", err)
+	}
+
+	// Get project and merge request IDs
+	projectID, mrID, err := c.mergeRequest(git)
+	if err != nil {
+		return false, err
+	}
+
+	// Get merge request labels, they may have changed since the pipeline start
+	mr, _, err := git.MergeRequests.GetMergeRequest(projectID, mrID, nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to get merge request: %w", err)
+	}
+
+	for _, l := range mr.Labels {
+		if l == label {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// LockHolder returns the pipeline holding the state lock lockID: pipeline
+// when set, otherwise the merge request pipeline whose running comment matches
+// the holder and creation time of the lock reported by gogci
+func (c *Client) LockHolder(lockID string, pipeline int) (int, error) {
+
+	if pipeline != 0 {
+		return pipeline, nil
+	}
+
+	// Init gitlab client
+	git, err := gitlab.NewClient(c.Token, gitlab.WithBaseURL(c.URL))
+	if err != nil {
+		return 0, fmt.Errorf("failed to init Gitlab client: %w", err)
+	}
+
+	// Get project and merge request IDs
+	projectID, mrID, err := c.mergeRequest(git)
+	if err != nil {
+		return 0, err
+	}
+
+	notes, err := c.listMergeRequestNotes(git, projectID, mrID)
+	if err != nil {
+		return 0, err
+	}
+
+	// Only markers written by gogci are trusted
+	notes, err = c.ownNotes(git, notes)
+	if err != nil {
+		return 0, err
+	}
+
+	wd, err := projectDir()
+	if err != nil {
+		return 0, err
+	}
+
+	// Find the lock reported on the merge request and the pipelines blocked by it
+	var lock *terraform.Lock
+	blocked := map[string]bool{}
+	for _, note := range notes {
+		match := lockMarkerRegexp.FindStringSubmatch(note.Body)
+		if match != nil && match[1] == wd && match[2] == c.Workspace && match[3] == lockID {
+			lock = &terraform.Lock{ID: match[3], Who: match[4], Created: match[5]}
+			blocked[match[6]] = true
+		}
+	}
+	if lock == nil {
+		return 0, fmt.Errorf("lock %s was not reported by gogci on the merge request in dir %s, its holder pipeline must be set", lockID, wd)
+	}
+	created, err := lock.CreatedTime()
+	if err != nil {
+		return 0, err
+	}
+
+	// Find the running comment of the holder, the closest before the lock
+	holder := 0
+	var holderAt time.Time
+	for _, note := range notes {
+		match := runMarkerRegexp.FindStringSubmatch(note.Body)
+		if match == nil || match[1] != wd || match[2] != c.Workspace || match[4] != lock.Who || blocked[match[3]] || note.CreatedAt == nil {
+			continue
+		}
+		at := *note.CreatedAt
+		if at.After(created.Add(time.Minute)) || at.Before(created.Add(-lockHolderWindow)) {
+			continue
+		}
+		id, err := strconv.Atoi(match[3])
+		if err != nil {
+			continue
+		}
+		if holder == 0 || at.After(holderAt) {
+			holder, holderAt = id, at
+		}
+	}
+	if holder == 0 {
+		return 0, fmt.Errorf("no pipeline of the merge request matches holder %q of lock %s created at %s, its holder pipeline must be set", lock.Who, lockID, lock.Created)
+	}
+
+	return holder, nil
+}
+
+// PipelineRunning returns true when the pipeline has not finished
+func (c *Client) PipelineRunning(pipeline int) (bool, error) {
+
+	// Init gitlab client
+	git, err := gitlab.NewClient(c.Token, gitlab.WithBaseURL(c.URL))
+	if err != nil {
+		return false, fmt.Errorf("failed to init Gitlab client: %w", err)
+	}
+
+	// Get project ID
+	projectID, _, err := c.mergeRequest(git)
+	if err != nil {
+		return false, err
+	}
+
+	p, _, err := git.Pipelines.GetPipeline(projectID, pipeline)
+	if err != nil {
+		return false, fmt.Errorf("failed to get pipeline %d: %w", pipeline, err)
+	}
+
+	return runningPipelineStatuses[p.Status], nil
+}
@@ -1,33 +1,28 @@
 package gitlab
 
 import (
-	"errors"
 	"fmt"
-	"os"
-	"strconv"
 
 	"github.com/xanzy/go-gitlab"
 )
 
 func (c *Client) CheckOldestMergeRequest() (bool, error) {
 
+	// Merge order is already settled once the merge request is merged
+	if c.PostMerge() {
+		return true, nil
+	}
+
 	// Init gitlab client
 	git, err := gitlab.NewClient(c.Token, gitlab.WithBaseURL(c.URL))
 	if err != nil {
 		return false, fmt.Errorf("failed to init Gitlab client: %w", err)
 	}
 
-	// Get project and merge request IDs from Gitlab CI env vars
-	if os.Getenv("CI_PROJECT_ID") == "" || os.Getenv("CI_MERGE_REQUEST_IID") == "" {
-		return false, errors.New("CI_PROJECT_ID or CI_MERGE_REQUEST_IID env var is not defined, GOGCI must run in a Merge Request")
-	}
-	projectID, err := strconv.Atoi(os.Getenv("CI_PROJECT_ID"))
-	if err != nil {
-		return false, fmt.Errorf("failed to parse CI_PROJECT_ID env var: %w", err)
-	}
-	mrIID, err := strconv.Atoi(os.Getenv("CI_MERGE_REQUEST_IID"))
+	// Get project and merge request IDs
+	projectID, mrIID, err := c.mergeRequest(git)
 	if err != nil {
-		return false, fmt.Errorf("failed to parse CI_MERGE_REQUEST_IID env var: %w", err)
+		return false, err
 	}
 
 	// Get project open merge requests
@@ -2,17 +2,42 @@ package gitlab
 
 import (
 	"bytes"
-	"errors"
 	"fmt"
 	"os"
-	"regexp"
-	"strconv"
 	"strings"
 	"text/template"
 
+	"github.com/Ouest-France/gogci/policy"
+	"github.com/Ouest-France/gogci/terraform"
 	"github.com/xanzy/go-gitlab"
 )
 
+// policyResultsTemplate renders policy check results as a markdown list
+var policyResultsTemplate = `{{range .Policies}}
+- {{if .Passed}}:white_check_mark:{{else if .Blocking}}:no_entry:{{else}}:warning:{{end}} ` + "`{{.Rule}}`" + `{{range .Violations}}
+  - {{.}}{{end}}{{end}}`
+
+// excerpt returns the last lines of an output
+func excerpt(output string, lines int) string {
+	all := strings.Split(strings.TrimSpace(output), "\n")
+	if len(all) > lines {
+		all = append([]string{"[...]"}, all[len(all)-lines:]...)
+	}
+	return strings.Join(all, "\n")
+}
+
+// unitSummary is the summary of a single root module
+type unitSummary struct {
+	Name, Summary string
+}
+
+// unitSummariesTemplate renders summaries as a table when there are several units
+var unitSummariesTemplate = `{{if gt (len .Units) 1}}
+| Unit | Summary |
+| ---- | ------- |
+{{range .Units}}| ` + "`{{.Name}}`" + ` | {{.Summary}} |
+{{end}}{{end}}`
+
 func (c *Client) CreateMergeRequestNote(tmpl string, data interface{}) error {
 
 	// Init gitlab client
@@ -40,17 +65,10 @@ func (c *Client) CreateMergeRequestNote(tmpl string, data interface{}) error {
 		Body: &body,
 	}
 
-	// Get project and merge request IDs from Gitlab CI env vars
-	if os.Getenv("CI_PROJECT_ID") == "" || os.Getenv("CI_MERGE_REQUEST_IID") == "" {
-		return errors.New("CI_PROJECT_ID or CI_MERGE_REQUEST_IID env var is not defined, GOGCI must run in a Merge Request")
-	}
-	projectID, err := strconv.Atoi(os.Getenv("CI_PROJECT_ID"))
-	if err != nil {
-		return fmt.Errorf("failed to parse CI_PROJECT_ID env var: %w", err)
-	}
-	mrID, err := strconv.Atoi(os.Getenv("CI_MERGE_REQUEST_IID"))
+	// Get project and merge request IDs
+	projectID, mrID, err := c.mergeRequest(git)
 	if err != nil {
-		return fmt.Errorf("failed to parse CI_MERGE_REQUEST_IID env var: %w", err)
+		return err
 	}
 
 	// Create comment on MR
@@ -62,10 +80,18 @@ func (c *Client) CreateMergeRequestNote(tmpl string, data interface{}) error {
 	return nil
 }
 
-func (c *Client) TerraformInitFailed() error {
+func (c *Client) TerraformInitFailed(output string) error {
 
-	var notif = " :red_circle: Terraform init **failed** in dir `{{.Dir}}` for commit `{{.Commit}}` in pipeline `{{.PipelineID}}`." + `
+	var notif = " :red_circle: Terraform init **failed** in dir `{{.Dir}}`{{if .Workspace}} for workspace `{{.Workspace}}`{{end}} for commit `{{.Commit}}` in pipeline `{{.PipelineID}}`." + `
+{{if .Stderr}}
+<details><summary>Error output</summary>
 
+` + "```" + `
+{{.Stderr}}
+` + "```" + `
+
+</details>
+{{end}}
 :memo: [see job log]({{.Job}}) | :arrow_forward: [see pipeline]({{.PipelineURL}})`
 
 	// Get working directory
@@ -82,13 +108,15 @@ func (c *Client) TerraformInitFailed() error {
 
 	// Collect data for templating
 	data := struct {
-		Dir, Commit, Job, PipelineID, PipelineURL string
+		Dir, Workspace, Commit, Job, PipelineID, PipelineURL, Stderr string
 	}{
 		Dir:         wd,
+		Workspace:   c.Workspace,
 		Commit:      os.Getenv("CI_COMMIT_SHORT_SHA"),
 		Job:         os.Getenv("CI_JOB_URL"),
 		PipelineID:  os.Getenv("CI_PIPELINE_ID"),
 		PipelineURL: os.Getenv("CI_PIPELINE_URL"),
+		Stderr:      excerpt(output, 30),
 	}
 
 	// Create comment
@@ -102,9 +130,10 @@ func (c *Client) TerraformInitFailed() error {
 
 func (c *Client) TerraformPlanRunning() error {
 
-	var notif = "Terraform plan running in dir `{{.Dir}}` for commit `{{.Commit}}` in pipeline `{{.PipelineID}}`." + `
+	var notif = "Terraform plan running in dir `{{.Dir}}`{{if .Workspace}} for workspace `{{.Workspace}}`{{end}} for commit `{{.Commit}}` in pipeline `{{.PipelineID}}`." + `
 
-:memo: [see job log]({{.Job}}) | :arrow_forward: [see pipeline]({{.PipelineURL}})`
+:memo: [see job log]({{.Job}}) | :arrow_forward: [see pipeline]({{.PipelineURL}})
+{{.Marker}}`
 
 	// Get working directory
 	wd, err := os.Getwd()
@@ -120,13 +149,15 @@ func (c *Client) TerraformPlanRunning() error {
 
 	// Collect data for templating
 	data := struct {
-		Dir, Commit, Job, PipelineID, PipelineURL string
+		Dir, Workspace, Commit, Job, PipelineID, PipelineURL, Marker string
 	}{
 		Dir:         wd,
+		Workspace:   c.Workspace,
 		Commit:      os.Getenv("CI_COMMIT_SHORT_SHA"),
 		Job:         os.Getenv("CI_JOB_URL"),
 		PipelineID:  os.Getenv("CI_PIPELINE_ID"),
 		PipelineURL: os.Getenv("CI_PIPELINE_URL"),
+		Marker:      runMarker(wd, c.Workspace),
 	}
 
 	// Create comment
@@ -140,9 +171,10 @@ func (c *Client) TerraformPlanRunning() error {
 
 func (c *Client) TerraformPlanFailed(output string) error {
 
-	var notif = " :red_circle: Terraform plan **failed** in dir `{{.Dir}}` for commit `{{.Commit}}` in pipeline `{{.PipelineID}}`." + `
+	var notif = " :red_circle: Terraform plan **failed** in dir `{{.Dir}}`{{if .Workspace}} for workspace `{{.Workspace}}`{{end}} for commit `{{.Commit}}` in pipeline `{{.PipelineID}}`." + `
 
-:memo: [see job log]({{.Job}}) | :arrow_forward: [see pipeline]({{.PipelineURL}})`
+:memo: [see job log]({{.Job}}) | :arrow_forward: [see pipeline]({{.PipelineURL}})
+{{.Marker}}`
 
 	// Get working directory
 	wd, err := os.Getwd()
@@ -158,14 +190,16 @@ func (c *Client) TerraformPlanFailed(output string) error {
 
 	// Collect data for templating
 	data := struct {
-		Dir, Commit, Job, PipelineID, PipelineURL, Stdout string
+		Dir, Workspace, Commit, Job, PipelineID, PipelineURL, Stdout, Marker string
 	}{
 		Dir:         wd,
+		Workspace:   c.Workspace,
 		Commit:      os.Getenv("CI_COMMIT_SHORT_SHA"),
 		Job:         os.Getenv("CI_JOB_URL"),
 		PipelineID:  os.Getenv("CI_PIPELINE_ID"),
 		PipelineURL: os.Getenv("CI_PIPELINE_URL"),
 		Stdout:      output,
+		Marker:      outcomeMarker(wd, c.Workspace, c.headCommit(), LabelFailed),
 	}
 
 	// Create comment
@@ -177,13 +211,18 @@ func (c *Client) TerraformPlanFailed(output string) error {
 	return nil
 }
 
-func (c *Client) TerraformPlanSummary(output string) error {
+func (c *Client) TerraformPlanSummary(units []terraform.Unit, policies []policy.Result) error {
 
-	var notif = "Terraform plan ran in dir `{{.Dir}}` for commit `{{.Commit}}` in pipeline `{{.PipelineID}}`." + `
+	var notif = "Terraform plan ran{{if .Tool}} with `{{.Tool}}`{{end}} in dir `{{.Dir}}`{{if .Workspace}} for workspace `{{.Workspace}}`{{end}} for commit `{{.Commit}}` in pipeline `{{.PipelineID}}`." + `
 
 **Plan summary**: {{.Summary}}
+` + unitSummariesTemplate + `{{if .Policies}}
+**Policy checks**:
+` + policyResultsTemplate + `
+{{end}}
+:memo: [see job log]({{.Job}}) | :arrow_forward: [see pipeline]({{.PipelineURL}})
 
-:memo: [see job log]({{.Job}}) | :arrow_forward: [see pipeline]({{.PipelineURL}})`
+{{.Marker}}`
 
 	// Get working directory
 	wd, err := os.Getwd()
@@ -197,32 +236,34 @@ func (c *Client) TerraformPlanSummary(output string) error {
 		wd = "."
 	}
 
-	// Extract summary
-	nochanges, err := regexp.MatchString("No changes. Infrastructure is up-to-date.", output)
-	if err != nil {
-		return fmt.Errorf("failed to match string: %w", err)
+	// Extract summaries
+	summary := terraform.TotalPlanSummary(units).Text
+	summaries := []unitSummary{}
+	for _, unit := range units {
+		summaries = append(summaries, unitSummary{Name: unit.Name, Summary: terraform.ParsePlanSummary(unit.Output).Text})
 	}
 
-	summary := "No changes. Infrastructure is up-to-date."
-	if !nochanges {
-		r, err := regexp.Compile("([0-9]+) to add, ([0-9]+) to change, ([0-9]+) to destroy")
-		if err != nil {
-			return fmt.Errorf("failed to compile regex: %w", err)
-		}
-		summary = r.FindString(output)
-	}
+	// Record plan fingerprint for approval checks and outcome for labels
+	marker := planMarker(wd, c.Workspace, c.headCommit(), terraform.PlanFingerprint(units)) + "\n" +
+		outcomeMarker(wd, c.Workspace, c.headCommit(), PlanLabel(terraform.TotalPlanSummary(units)))
 
 	// Collect data for templating
 	data := struct {
-		Dir, Commit, Job, PipelineID, PipelineURL, Summary, Stdout string
+		Dir, Workspace, Commit, Job, PipelineID, PipelineURL, Summary, Marker, Tool string
+		Units                                                                       []unitSummary
+		Policies                                                                    []policy.Result
 	}{
 		Dir:         wd,
+		Workspace:   c.Workspace,
 		Commit:      os.Getenv("CI_COMMIT_SHORT_SHA"),
 		Job:         os.Getenv("CI_JOB_URL"),
 		PipelineID:  os.Getenv("CI_PIPELINE_ID"),
 		PipelineURL: os.Getenv("CI_PIPELINE_URL"),
 		Summary:     summary,
-		Stdout:      output,
+		Marker:      marker,
+		Tool:        c.Tool,
+		Units:       summaries,
+		Policies:    policies,
 	}
 
 	// Create comment
@@ -234,11 +275,15 @@ func (c *Client) TerraformPlanSummary(output string) error {
 	return nil
 }
 
-func (c *Client) TerraformApplyRunning() error {
+func (c *Client) TerraformApplyRunning(backup terraform.StateBackup) error {
 
-	var notif = "Terraform apply running in dir `{{.Dir}}` for commit `{{.Commit}}` in pipeline `{{.PipelineID}}`." + `
-
-:memo: [see job log]({{.Job}}) | :arrow_forward: [see pipeline]({{.PipelineURL}})`
+	var notif = "Terraform apply running in dir `{{.Dir}}`{{if .Workspace}} for workspace `{{.Workspace}}`{{end}} for commit `{{.Commit}}` in pipeline `{{.PipelineID}}`." + `
+{{if .Backup.File}}
+:floppy_disk: State backed up to ` + "`{{.Backup.File}}`" + ` (sha256 ` + "`{{.Backup.SHA256}}`" + `).
+{{.BackupMarker}}
+{{end}}
+:memo: [see job log]({{.Job}}) | :arrow_forward: [see pipeline]({{.PipelineURL}})
+{{.Marker}}`
 
 	// Get working directory
 	wd, err := os.Getwd()
@@ -254,13 +299,20 @@ func (c *Client) TerraformApplyRunning() error {
 
 	// Collect data for templating
 	data := struct {
-		Dir, Commit, Job, PipelineID, PipelineURL string
+		Dir, Workspace, Commit, Job, PipelineID, PipelineURL, Marker, BackupMarker string
+		Backup                                                                     terraform.StateBackup
 	}{
 		Dir:         wd,
+		Workspace:   c.Workspace,
 		Commit:      os.Getenv("CI_COMMIT_SHORT_SHA"),
 		Job:         os.Getenv("CI_JOB_URL"),
 		PipelineID:  os.Getenv("CI_PIPELINE_ID"),
 		PipelineURL: os.Getenv("CI_PIPELINE_URL"),
+		Marker:      runMarker(wd, c.Workspace),
+	}
+	if backup.File != "" {
+		data.Backup = backup
+		data.BackupMarker = stateBackupMarker(wd, c.Workspace, backup.SHA256)
 	}
 
 	// Create comment
@@ -274,7 +326,7 @@ func (c *Client) TerraformApplyRunning() error {
 
 func (c *Client) TerraformApplyFailed(output string) error {
 
-	var notif = " :red_circle: Terraform apply **failed** in dir `{{.Dir}}` for commit `{{.Commit}}` in pipeline `{{.PipelineID}}`." + `
+	var notif = " :red_circle: Terraform apply **failed** in dir `{{.Dir}}`{{if .Workspace}} for workspace `{{.Workspace}}`{{end}} for commit `{{.Commit}}` in pipeline `{{.PipelineID}}`." + `
 
 :memo: [see job log]({{.Job}}) | :arrow_forward: [see pipeline]({{.PipelineURL}})`
 
@@ -292,9 +344,10 @@ func (c *Client) TerraformApplyFailed(output string) error {
 
 	// Collect data for templating
 	data := struct {
-		Dir, Commit, Job, PipelineID, PipelineURL, Stdout string
+		Dir, Workspace, Commit, Job, PipelineID, PipelineURL, Stdout string
 	}{
 		Dir:         wd,
+		Workspace:   c.Workspace,
 		Commit:      os.Getenv("CI_COMMIT_SHORT_SHA"),
 		Job:         os.Getenv("CI_JOB_URL"),
 		PipelineID:  os.Getenv("CI_PIPELINE_ID"),
@@ -311,12 +364,12 @@ func (c *Client) TerraformApplyFailed(output string) error {
 	return nil
 }
 
-func (c *Client) TerraformApplySummary(output string) error {
+func (c *Client) TerraformApplySummary(units []terraform.Unit) error {
 
-	var notif = "Terraform apply ran in dir `{{.Dir}}` for commit `{{.Commit}}` in pipeline `{{.PipelineID}}`." + `
+	var notif = "Terraform apply ran{{if .Tool}} with `{{.Tool}}`{{end}} in dir `{{.Dir}}`{{if .Workspace}} for workspace `{{.Workspace}}`{{end}} for commit `{{.Commit}}` in pipeline `{{.PipelineID}}`." + `
 
 **Apply summary**: {{.Summary}}
-
+` + unitSummariesTemplate + `
 :memo: [see job log]({{.Job}}) | :arrow_forward: [see pipeline]({{.PipelineURL}})`
 
 	// Get working directory
@@ -331,24 +384,30 @@ func (c *Client) TerraformApplySummary(output string) error {
 		wd = "."
 	}
 
-	// Extract summary
-	r, err := regexp.Compile("([0-9]+) added, ([0-9]+) changed, ([0-9]+) destroyed")
-	if err != nil {
-		return fmt.Errorf("failed to compile terraform plan output regex: %w", err)
+	// Extract summaries
+	summaries := []unitSummary{}
+	for _, unit := range units {
+		summaries = append(summaries, unitSummary{Name: unit.Name, Summary: terraform.ParseApplySummary(unit.Output)})
+	}
+	summary := ""
+	if len(summaries) == 1 {
+		summary = summaries[0].Summary
 	}
-	summary := r.FindString(output)
 
 	// Collect data for templating
 	data := struct {
-		Dir, Commit, Job, PipelineID, PipelineURL, Summary, Stdout string
+		Dir, Workspace, Commit, Job, PipelineID, PipelineURL, Summary, Tool string
+		Units                                                               []unitSummary
 	}{
 		Dir:         wd,
+		Workspace:   c.Workspace,
 		Commit:      os.Getenv("CI_COMMIT_SHORT_SHA"),
 		Job:         os.Getenv("CI_JOB_URL"),
 		PipelineID:  os.Getenv("CI_PIPELINE_ID"),
 		PipelineURL: os.Getenv("CI_PIPELINE_URL"),
 		Summary:     summary,
-		Stdout:      output,
+		Tool:        c.Tool,
+		Units:       summaries,
 	}
 
 	// Create comment
@@ -360,9 +419,11 @@ func (c *Client) TerraformApplySummary(output string) error {
 	return nil
 }
 
-func (c *Client) TerraformApplyNotApproved() error {
+func (c *Client) TerraformApplyNotApproved(reasons []string) error {
 
-	var notif = ":no_entry: Terraform apply **not authorized**, merge request must be approved, in dir `{{.Dir}}` for commit `{{.Commit}}` in pipeline `{{.PipelineID}}`." + `
+	var notif = ":no_entry: Terraform apply **not authorized**, merge request must be approved, in dir `{{.Dir}}`{{if .Workspace}} for workspace `{{.Workspace}}`{{end}} for commit `{{.Commit}}` in pipeline `{{.PipelineID}}`." + `
+{{range .Reasons}}
+- {{.}}{{end}}
 
 :memo: [see job log]({{.Job}}) | :arrow_forward: [see pipeline]({{.PipelineURL}})`
 
@@ -380,13 +441,16 @@ func (c *Client) TerraformApplyNotApproved() error {
 
 	// Collect data for templating
 	data := struct {
-		Dir, Commit, Job, PipelineID, PipelineURL, Stdout string
+		Dir, Workspace, Commit, Job, PipelineID, PipelineURL string
+		Reasons                                              []string
 	}{
 		Dir:         wd,
+		Workspace:   c.Workspace,
 		Commit:      os.Getenv("CI_COMMIT_SHORT_SHA"),
 		Job:         os.Getenv("CI_JOB_URL"),
 		PipelineID:  os.Getenv("CI_PIPELINE_ID"),
 		PipelineURL: os.Getenv("CI_PIPELINE_URL"),
+		Reasons:     reasons,
 	}
 
 	// Create comment
@@ -400,7 +464,7 @@ func (c *Client) TerraformApplyNotApproved() error {
 
 func (c *Client) TerraformApplyBlocked() error {
 
-	var notif = ":no_entry: Terraform apply **blocked**, all older merge requests must be closed, in dir `{{.Dir}}` for commit `{{.Commit}}` in pipeline `{{.PipelineID}}`." + `
+	var notif = ":no_entry: Terraform apply **blocked**, all older merge requests must be closed, in dir `{{.Dir}}`{{if .Workspace}} for workspace `{{.Workspace}}`{{end}} for commit `{{.Commit}}` in pipeline `{{.PipelineID}}`." + `
 
 :memo: [see job log]({{.Job}}) | :arrow_forward: [see pipeline]({{.PipelineURL}})`
 
@@ -418,9 +482,10 @@ func (c *Client) TerraformApplyBlocked() error {
 
 	// Collect data for templating
 	data := struct {
-		Dir, Commit, Job, PipelineID, PipelineURL, Stdout string
+		Dir, Workspace, Commit, Job, PipelineID, PipelineURL, Stdout string
 	}{
 		Dir:         wd,
+		Workspace:   c.Workspace,
 		Commit:      os.Getenv("CI_COMMIT_SHORT_SHA"),
 		Job:         os.Getenv("CI_JOB_URL"),
 		PipelineID:  os.Getenv("CI_PIPELINE_ID"),
@@ -435,3 +500,120 @@ func (c *Client) TerraformApplyBlocked() error {
 
 	return err
 }
+
+func (c *Client) TerraformApplyPolicyFailed(policies []policy.Result) error {
+
+	var notif = ":no_entry: Terraform apply **not authorized**, blocking policy checks failed, in dir `{{.Dir}}`{{if .Workspace}} for workspace `{{.Workspace}}`{{end}} for commit `{{.Commit}}` in pipeline `{{.PipelineID}}`." + `
+` + policyResultsTemplate + `
+
+:memo: [see job log]({{.Job}}) | :arrow_forward: [see pipeline]({{.PipelineURL}})`
+
+	// Get working directory
+	wd, err := projectDir()
+	if err != nil {
+		return err
+	}
+
+	// Collect data for templating
+	data := struct {
+		Dir, Workspace, Commit, Job, PipelineID, PipelineURL string
+		Policies                                             []policy.Result
+	}{
+		Dir:         wd,
+		Workspace:   c.Workspace,
+		Commit:      os.Getenv("CI_COMMIT_SHORT_SHA"),
+		Job:         os.Getenv("CI_JOB_URL"),
+		PipelineID:  os.Getenv("CI_PIPELINE_ID"),
+		PipelineURL: os.Getenv("CI_PIPELINE_URL"),
+		Policies:    policies,
+	}
+
+	// Create comment
+	err = c.CreateMergeRequestNote(notif, data)
+	if err != nil {
+		return fmt.Errorf("failed to create merge request comment: %w", err)
+	}
+
+	return nil
+}
+
+// EnvironmentPlan is the plan outcome of one environment of a matrix
+type EnvironmentPlan struct {
+	Name, Workspace string
+	Units           []terraform.Unit
+	Failed          bool
+}
+
+// Key returns the workspace keying statuses and plan records of the
+// environment, its name when it uses a separate backend without workspace
+func (p EnvironmentPlan) Key() string {
+	if p.Workspace == "" {
+		return p.Name
+	}
+	return p.Workspace
+}
+
+func (c *Client) TerraformPlanMatrix(plans []EnvironmentPlan) error {
+
+	var notif = "Terraform plan ran{{if .Tool}} with `{{.Tool}}`{{end}} in dir `{{.Dir}}` for commit `{{.Commit}}` in pipeline `{{.PipelineID}}`." + `
+
+| Environment | Add | Change | Destroy | Summary |
+| ----------- | --- | ------ | ------- | ------- |
+{{range .Environments}}| ` + "`{{.Name}}`" + ` | {{if .Failed}}-{{else}}{{.Summary.Add}}{{end}} | {{if .Failed}}-{{else}}{{.Summary.Change}}{{end}} | {{if .Failed}}-{{else}}{{.Summary.Destroy}}{{end}} | {{if .Failed}}:red_circle: **failed**{{else}}{{.Summary.Text}}{{end}} |
+{{end}}
+:memo: [see job log]({{.Job}}) | :arrow_forward: [see pipeline]({{.PipelineURL}})
+
+{{range .Markers}}{{.}}
+{{end}}`
+
+	// Get working directory
+	wd, err := projectDir()
+	if err != nil {
+		return err
+	}
+
+	// Extract summaries and record plan fingerprints and outcomes keyed as statuses
+	type environment struct {
+		Name    string
+		Failed  bool
+		Summary terraform.PlanSummary
+	}
+	environments := []environment{}
+	markers := []string{}
+	for _, plan := range plans {
+		summary := terraform.TotalPlanSummary(plan.Units)
+		environments = append(environments, environment{Name: plan.Name, Failed: plan.Failed, Summary: summary})
+		if plan.Failed {
+			markers = append(markers, outcomeMarker(wd, plan.Key(), c.headCommit(), LabelFailed))
+			continue
+		}
+		markers = append(markers,
+			planMarker(wd, plan.Key(), c.headCommit(), terraform.PlanFingerprint(plan.Units)),
+			outcomeMarker(wd, plan.Key(), c.headCommit(), PlanLabel(summary)),
+		)
+	}
+
+	// Collect data for templating
+	data := struct {
+		Dir, Commit, Job, PipelineID, PipelineURL, Tool string
+		Environments                                    []environment
+		Markers                                         []string
+	}{
+		Dir:          wd,
+		Commit:       os.Getenv("CI_COMMIT_SHORT_SHA"),
+		Job:          os.Getenv("CI_JOB_URL"),
+		PipelineID:   os.Getenv("CI_PIPELINE_ID"),
+		PipelineURL:  os.Getenv("CI_PIPELINE_URL"),
+		Tool:         c.Tool,
+		Environments: environments,
+		Markers:      markers,
+	}
+
+	// Create comment
+	err = c.CreateMergeRequestNote(notif, data)
+	if err != nil {
+		return fmt.Errorf("failed to create merge request comment: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,73 @@
+package gitlab
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/xanzy/go-gitlab"
+)
+
+// planMarkerRegexp matches the hidden marker added to plan summary comments
+var planMarkerRegexp = regexp.MustCompile(`<!-- gogci:plan dir="([^"]*)"(?: workspace="([^"]*)")? commit="([^"]*)" fingerprint="([^"]*)" -->`)
+
+// planRecord is a plan fingerprint recorded in a merge request comment
+type planRecord struct {
+	Dir, Workspace, Commit, Fingerprint string
+	CreatedAt                           time.Time
+}
+
+// planMarker returns the hidden marker recording a plan fingerprint
+func planMarker(dir, workspace, commit, fingerprint string) string {
+	if workspace == "" {
+		return fmt.Sprintf(`<!-- gogci:plan dir="%s" commit="%s" fingerprint="%s" -->`, dir, commit, fingerprint)
+	}
+	return fmt.Sprintf(`<!-- gogci:plan dir="%s" workspace="%s" commit="%s" fingerprint="%s" -->`, dir, workspace, commit, fingerprint)
+}
+
+// planRecords returns the latest plan fingerprint recorded for each commit in
+// dir and workspace, notes must be the ones written by the token user
+func planRecords(notes []*gitlab.Note, dir, workspace string) map[string]planRecord {
+
+	records := map[string]planRecord{}
+	for _, note := range notes {
+		if note.CreatedAt == nil {
+			continue
+		}
+
+		match := planMarkerRegexp.FindStringSubmatch(note.Body)
+		if match == nil || match[1] != dir || match[2] != workspace {
+			continue
+		}
+
+		records[match[3]] = planRecord{
+			Dir:         match[1],
+			Workspace:   match[2],
+			Commit:      match[3],
+			Fingerprint: match[4],
+			CreatedAt:   *note.CreatedAt,
+		}
+	}
+
+	return records
+}
+
+// projectDir returns the working directory relative to the project dir
+func projectDir() (string, error) {
+
+	// Get working directory
+	wd, err := os.Getwd()
+	if err != nil {
+		return "", fmt.Errorf("failed to get working directory: %w", err)
+	}
+
+	// Extract subdir in path
+	wd = strings.Replace(wd, os.Getenv("CI_PROJECT_DIR"), "", 1)
+	if wd == "" {
+		wd = "."
+	}
+
+	return wd, nil
+}
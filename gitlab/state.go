@@ -0,0 +1,97 @@
+package gitlab
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+
+	"github.com/xanzy/go-gitlab"
+)
+
+// stateBackupMarkerRegexp matches the hidden marker recording state backups
+var stateBackupMarkerRegexp = regexp.MustCompile(`<!-- gogci:state-backup dir="([^"]*)"(?: workspace="([^"]*)")? sha256="([0-9a-f]+)" -->`)
+
+// stateBackupMarker returns the hidden marker recording a state backup checksum
+func stateBackupMarker(dir, workspace, checksum string) string {
+	if workspace == "" {
+		return fmt.Sprintf(`<!-- gogci:state-backup dir="%s" sha256="%s" -->`, dir, checksum)
+	}
+	return fmt.Sprintf(`<!-- gogci:state-backup dir="%s" workspace="%s" sha256="%s" -->`, dir, workspace, checksum)
+}
+
+// CheckStateBackup returns true when a state backup with checksum was
+// recorded by gogci on the merge request for the same dir and workspace
+func (c *Client) CheckStateBackup(checksum string) (bool, error) {
+
+	// Init gitlab client
+	git, err := gitlab.NewClient(c.Token, gitlab.WithBaseURL(c.URL))
+	if err != nil {
+		return false, fmt.Errorf("failed to init Gitlab client: %w", err)
+	}
+
+	// Get project and merge request IDs
+	projectID, mrID, err := c.mergeRequest(git)
+	if err != nil {
+		return false, err
+	}
+
+	notes, err := c.listMergeRequestNotes(git, projectID, mrID)
+	if err != nil {
+		return false, err
+	}
+
+	// Only backups recorded by gogci are trusted
+	notes, err = c.ownNotes(git, notes)
+	if err != nil {
+		return false, err
+	}
+
+	wd, err := projectDir()
+	if err != nil {
+		return false, err
+	}
+
+	for _, note := range notes {
+		for _, match := range stateBackupMarkerRegexp.FindAllStringSubmatch(note.Body, -1) {
+			if match[1] == wd && match[2] == c.Workspace && match[3] == checksum {
+				return true, nil
+			}
+		}
+	}
+
+	return false, nil
+}
+
+func (c *Client) TerraformStateRestored(file, checksum string) error {
+
+	var notif = ":rewind: Terraform state restored from `{{.File}}` (sha256 `{{.SHA256}}`) in dir `{{.Dir}}`{{if .Workspace}} for workspace `{{.Workspace}}`{{end}} in pipeline `{{.PipelineID}}`." + `
+
+:memo: [see job log]({{.Job}}) | :arrow_forward: [see pipeline]({{.PipelineURL}})`
+
+	// Get working directory
+	wd, err := projectDir()
+	if err != nil {
+		return err
+	}
+
+	// Collect data for templating
+	data := struct {
+		File, SHA256, Dir, Workspace, Job, PipelineID, PipelineURL string
+	}{
+		File:        file,
+		SHA256:      checksum,
+		Dir:         wd,
+		Workspace:   c.Workspace,
+		Job:         os.Getenv("CI_JOB_URL"),
+		PipelineID:  os.Getenv("CI_PIPELINE_ID"),
+		PipelineURL: os.Getenv("CI_PIPELINE_URL"),
+	}
+
+	// Create comment
+	err = c.CreateMergeRequestNote(notif, data)
+	if err != nil {
+		return fmt.Errorf("failed to create merge request comment: %w", err)
+	}
+
+	return nil
+}
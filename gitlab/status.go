@@ -0,0 +1,90 @@
+package gitlab
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/xanzy/go-gitlab"
+)
+
+// Commit status states
+const (
+	StatusPending = "pending"
+	StatusRunning = "running"
+	StatusSuccess = "success"
+	StatusFailed  = "failed"
+)
+
+// SetCommitStatus sets the "gogci/<stage>:<dir>[@<workspace>]" status on the
+// pipeline commit, it does nothing unless commit statuses are enabled on the client
+func (c *Client) SetCommitStatus(stage, state, description string) error {
+
+	if !c.CommitStatus {
+		return nil
+	}
+
+	// Init gitlab client
+	git, err := gitlab.NewClient(c.Token, gitlab.WithBaseURL(c.URL))
+	if err != nil {
+		return fmt.Errorf("failed to init gitlab client: %w", err)
+	}
+
+	// Get project ID from Gitlab CI env vars
+	projectID, err := strconv.Atoi(os.Getenv("CI_PROJECT_ID"))
+	if err != nil {
+		return fmt.Errorf("failed to parse CI_PROJECT_ID env var: %w", err)
+	}
+
+	// Get working directory
+	wd, err := projectDir()
+	if err != nil {
+		return err
+	}
+
+	// Name status after stage, dir and workspace
+	name := fmt.Sprintf("gogci/%s:%s", stage, wd)
+	if c.Workspace != "" {
+		name = fmt.Sprintf("%s@%s", name, c.Workspace)
+	}
+
+	// Set status options
+	opt := &gitlab.SetCommitStatusOptions{
+		State:       gitlab.BuildStateValue(state),
+		Name:        gitlab.String(name),
+		TargetURL:   gitlab.String(os.Getenv("CI_JOB_URL")),
+		Description: gitlab.String(description),
+	}
+	if ref := os.Getenv("CI_COMMIT_REF_NAME"); ref != "" {
+		opt.Ref = gitlab.String(ref)
+	}
+	if pipelineID, err := strconv.Atoi(os.Getenv("CI_PIPELINE_ID")); err == nil {
+		opt.PipelineID = gitlab.Int(pipelineID)
+	}
+
+	// Set commit status
+	_, _, err = git.Commits.SetCommitStatus(projectID, os.Getenv("CI_COMMIT_SHA"), opt)
+	if err != nil {
+		return fmt.Errorf("failed to set commit status: %w", err)
+	}
+
+	// Remember state to fail unfinished statuses
+	if c.statuses == nil {
+		c.statuses = map[string]string{}
+	}
+	c.statuses[stage] = state
+
+	return nil
+}
+
+// FailCommitStatus sets the stage status to failed when it was left pending
+// or running, statuses already finished are kept
+func (c *Client) FailCommitStatus(stage, description string) error {
+
+	switch c.statuses[stage] {
+	case StatusPending, StatusRunning:
+		return c.SetCommitStatus(stage, StatusFailed, description)
+	default:
+		return nil
+	}
+}
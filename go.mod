@@ -5,10 +5,13 @@ go 1.14
 require (
 	github.com/Masterminds/sprig/v3 v3.2.2
 	github.com/acarl005/stripansi v0.0.0-20180116102854-5a71ef0e047d
+	github.com/aws/aws-sdk-go v1.44.56
 	github.com/fatih/color v1.13.0
+	github.com/hashicorp/go-version v1.2.0
 	github.com/hashicorp/vault/api v1.7.2
 	github.com/mitchellh/go-homedir v1.1.0
 	github.com/spf13/cobra v1.5.0
+	github.com/spf13/pflag v1.0.5
 	github.com/spf13/viper v1.12.0
 	github.com/xanzy/go-gitlab v0.70.0
 	gopkg.in/ini.v1 v1.66.6
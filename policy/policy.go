@@ -0,0 +1,197 @@
+package policy
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/Ouest-France/gogci/terraform"
+	"github.com/spf13/viper"
+)
+
+// Rule is a check evaluated against a terraform plan
+type Rule struct {
+	Name string `mapstructure:"name"`
+
+	// Blocking rules prevent apply when they fail (default: true)
+	Blocking *bool `mapstructure:"blocking"`
+
+	// Resource types that must not be destroyed
+	DenyDeleteTypes []string `mapstructure:"deny_delete_types"`
+
+	// Resource address globs that must not be changed
+	ProtectedAddresses []string `mapstructure:"protected_addresses"`
+
+	// Maximum number of destroyed resources
+	MaxDestroys *int `mapstructure:"max_destroys"`
+}
+
+// Result is the outcome of a rule evaluation
+type Result struct {
+	Rule       string
+	Blocking   bool
+	Passed     bool
+	Violations []string
+}
+
+// Load reads rules from all YAML and JSON files of a directory, no directory
+// means no rules. Unknown keys are rejected, a misspelled one would disable
+// its check.
+func Load(dir string) ([]Rule, error) {
+
+	rules := []Rule{}
+	if dir == "" {
+		return rules, nil
+	}
+
+	// List policy files, a missing directory would let every plan through
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read policy dir %q: %w", dir, err)
+	}
+
+	files := []string{}
+	for _, entry := range entries {
+		switch filepath.Ext(entry.Name()) {
+		case ".yaml", ".yml", ".json":
+			if !entry.IsDir() {
+				files = append(files, filepath.Join(dir, entry.Name()))
+			}
+		}
+	}
+	sort.Strings(files)
+
+	// Read rules of each file
+	for _, file := range files {
+		v := viper.New()
+		v.SetConfigFile(file)
+		err := v.ReadInConfig()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read policy file %q: %w", file, err)
+		}
+
+		content := struct {
+			Rules []Rule `mapstructure:"rules"`
+		}{}
+		err = v.UnmarshalExact(&content)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode policy file %q: %w", file, err)
+		}
+		fileRules := content.Rules
+
+		for i, rule := range fileRules {
+			if rule.Name == "" {
+				fileRules[i].Name = fmt.Sprintf("%s#%d", filepath.Base(file), i+1)
+			}
+		}
+		rules = append(rules, fileRules...)
+	}
+
+	return rules, nil
+}
+
+// Evaluate checks each rule against the plan
+func Evaluate(rules []Rule, plan *terraform.Plan) []Result {
+
+	results := []Result{}
+	for _, rule := range rules {
+		result := Result{Rule: rule.Name, Blocking: rule.Blocking == nil || *rule.Blocking}
+
+		destroys := 0
+		for _, rc := range plan.ResourceChanges {
+			if rc.Deleted() {
+				destroys++
+
+				for _, t := range rule.DenyDeleteTypes {
+					if rc.Type == t {
+						result.Violations = append(result.Violations, fmt.Sprintf("`%s` of type `%s` is destroyed", rc.Address, rc.Type))
+					}
+				}
+			}
+
+			if rc.Changed() {
+				for _, glob := range rule.ProtectedAddresses {
+					if Match(glob, rc.Address) {
+						result.Violations = append(result.Violations, fmt.Sprintf("protected `%s` is changed (%s)", rc.Address, strings.Join(rc.Change.Actions, ", ")))
+						break
+					}
+				}
+			}
+		}
+
+		if rule.MaxDestroys != nil && destroys > *rule.MaxDestroys {
+			result.Violations = append(result.Violations, fmt.Sprintf("%d resources destroyed, at most %d allowed", destroys, *rule.MaxDestroys))
+		}
+
+		result.Passed = len(result.Violations) == 0
+		results = append(results, result)
+	}
+
+	return results
+}
+
+// Blocked returns true when a blocking rule failed
+func Blocked(results []Result) bool {
+	for _, result := range results {
+		if result.Blocking && !result.Passed {
+			return true
+		}
+	}
+	return false
+}
+
+// Match reports whether a resource address matches a glob where "*" matches
+// any sequence of characters
+func Match(glob, address string) bool {
+	parts := strings.Split(glob, "*")
+	for i, part := range parts {
+		parts[i] = regexp.QuoteMeta(part)
+	}
+	r := regexp.MustCompile("^" + strings.Join(parts, ".*") + "$")
+
+	return r.MatchString(address)
+}
+
+// Protected maps a resource address glob to Gitlab groups that must approve
+// changes on matching resources
+type Protected struct {
+	Address string   `mapstructure:"address"`
+	Groups  []string `mapstructure:"groups"`
+}
+
+// RequiredGroups returns, for each group, the changed resources requiring its approval
+func RequiredGroups(protected []Protected, plan *terraform.Plan) map[string][]string {
+
+	groups := map[string][]string{}
+	for _, rc := range plan.ResourceChanges {
+		if !rc.Changed() {
+			continue
+		}
+
+		for _, p := range protected {
+			if !Match(p.Address, rc.Address) {
+				continue
+			}
+
+			for _, group := range p.Groups {
+				if !contains(groups[group], rc.Address) {
+					groups[group] = append(groups[group], rc.Address)
+				}
+			}
+		}
+	}
+
+	return groups
+}
+
+func contains(list []string, value string) bool {
+	for _, v := range list {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,253 @@
+package policy
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/Ouest-France/gogci/terraform"
+)
+
+func boolPtr(b bool) *bool { return &b }
+
+func intPtr(i int) *int { return &i }
+
+func change(address, resourceType string, actions ...string) terraform.ResourceChange {
+	return terraform.ResourceChange{
+		Address: address,
+		Type:    resourceType,
+		Change:  terraform.Change{Actions: actions},
+	}
+}
+
+var testPlan = &terraform.Plan{
+	ResourceChanges: []terraform.ResourceChange{
+		change("aws_db_instance.main", "aws_db_instance", "delete", "create"),
+		change("aws_s3_bucket.logs", "aws_s3_bucket", "delete"),
+		change("module.network.aws_vpc.main", "aws_vpc", "update"),
+		change("aws_instance.web", "aws_instance", "no-op"),
+		change("data.aws_ami.ubuntu", "aws_ami", "read"),
+	},
+}
+
+func TestMatch(t *testing.T) {
+
+	tests := []struct {
+		glob    string
+		address string
+		want    bool
+	}{
+		{"aws_vpc.main", "aws_vpc.main", true},
+		{"aws_vpc.main", "aws_vpc.main2", false},
+		{"aws_vpc.*", "aws_vpc.main", true},
+		{"module.*.aws_vpc.main", "module.network.aws_vpc.main", true},
+		{"module.*", "aws_vpc.main", false},
+		{"*", "aws_vpc.main", true},
+		{`aws_instance.web["a"]`, `aws_instance.web["a"]`, true},
+		{"aws_instance.web[*]", "aws_instance.web[0]", true},
+		{"aws_instance.web[0]", "aws_instance.web0", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.glob+" "+tt.address, func(t *testing.T) {
+			if got := Match(tt.glob, tt.address); got != tt.want {
+				t.Errorf("Match(%q, %q) = %v, want %v", tt.glob, tt.address, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+
+	tests := []struct {
+		name    string
+		rule    Rule
+		want    Result
+		blocked bool
+	}{
+		{
+			name: "empty rule passes",
+			rule: Rule{Name: "empty"},
+			want: Result{Rule: "empty", Blocking: true, Passed: true},
+		},
+		{
+			name: "denied delete type, replacement included",
+			rule: Rule{Name: "db", DenyDeleteTypes: []string{"aws_db_instance"}},
+			want: Result{Rule: "db", Blocking: true, Violations: []string{
+				"`aws_db_instance.main` of type `aws_db_instance` is destroyed",
+			}},
+			blocked: true,
+		},
+		{
+			name: "protected addresses ignore no-op and read",
+			rule: Rule{Name: "protected", ProtectedAddresses: []string{"module.*.aws_vpc.*", "aws_instance.*", "data.*"}},
+			want: Result{Rule: "protected", Blocking: true, Violations: []string{
+				"protected `module.network.aws_vpc.main` is changed (update)",
+			}},
+			blocked: true,
+		},
+		{
+			name: "max destroys exceeded",
+			rule: Rule{Name: "max", MaxDestroys: intPtr(1)},
+			want: Result{Rule: "max", Blocking: true, Violations: []string{
+				"2 resources destroyed, at most 1 allowed",
+			}},
+			blocked: true,
+		},
+		{
+			name: "max destroys reached",
+			rule: Rule{Name: "max", MaxDestroys: intPtr(2)},
+			want: Result{Rule: "max", Blocking: true, Passed: true},
+		},
+		{
+			name: "non blocking rule",
+			rule: Rule{Name: "warn", Blocking: boolPtr(false), DenyDeleteTypes: []string{"aws_s3_bucket"}},
+			want: Result{Rule: "warn", Violations: []string{
+				"`aws_s3_bucket.logs` of type `aws_s3_bucket` is destroyed",
+			}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results := Evaluate([]Rule{tt.rule}, testPlan)
+			if len(results) != 1 {
+				t.Fatalf("Evaluate() returned %d results, want 1", len(results))
+			}
+			if !reflect.DeepEqual(results[0], tt.want) {
+				t.Errorf("Evaluate() = %+v, want %+v", results[0], tt.want)
+			}
+			if got := Blocked(results); got != tt.blocked {
+				t.Errorf("Blocked() = %v, want %v", got, tt.blocked)
+			}
+		})
+	}
+}
+
+func TestLoad(t *testing.T) {
+
+	tests := []struct {
+		name    string
+		files   map[string]string
+		dir     string
+		want    []Rule
+		wantErr bool
+	}{
+		{
+			name: "no dir",
+			want: []Rule{},
+		},
+		{
+			name:    "missing dir",
+			dir:     "missing",
+			wantErr: true,
+		},
+		{
+			name: "policy files",
+			files: map[string]string{
+				"a.yaml":   "rules:\n  - name: no-db-delete\n    deny_delete_types: [aws_db_instance]\n  - max_destroys: 3\n    blocking: false\n",
+				"b.json":   `{"rules": [{"protected_addresses": ["aws_vpc.*"]}]}`,
+				"notes.md": "not a policy",
+			},
+			want: []Rule{
+				{Name: "no-db-delete", DenyDeleteTypes: []string{"aws_db_instance"}},
+				{Name: "a.yaml#2", MaxDestroys: intPtr(3), Blocking: boolPtr(false)},
+				{Name: "b.json#1", ProtectedAddresses: []string{"aws_vpc.*"}},
+			},
+		},
+		{
+			name:  "empty dir",
+			files: map[string]string{},
+			want:  []Rule{},
+		},
+		{
+			name: "unknown rule key",
+			files: map[string]string{
+				"a.yaml": "rules:\n  - name: no-db-delete\n    deny_delete_type: [aws_db_instance]\n",
+			},
+			wantErr: true,
+		},
+		{
+			name: "unknown top level key",
+			files: map[string]string{
+				"a.json": `{"rule": [{"max_destroys": 0}]}`,
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := tt.dir
+			if tt.files != nil {
+				var err error
+				dir, err = ioutil.TempDir("", "policy")
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer os.RemoveAll(dir)
+
+				for name, content := range tt.files {
+					err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644)
+					if err != nil {
+						t.Fatal(err)
+					}
+				}
+			}
+
+			got, err := Load(dir)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Load() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Load() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRequiredGroups(t *testing.T) {
+
+	tests := []struct {
+		name      string
+		protected []Protected
+		want      map[string][]string
+	}{
+		{
+			name: "no protected resources",
+			want: map[string][]string{},
+		},
+		{
+			name: "unchanged protected resources",
+			protected: []Protected{
+				{Address: "aws_instance.*", Groups: []string{"ops"}},
+				{Address: "data.*", Groups: []string{"ops"}},
+			},
+			want: map[string][]string{},
+		},
+		{
+			name: "changed protected resources",
+			protected: []Protected{
+				{Address: "aws_db_instance.*", Groups: []string{"dba", "ops"}},
+				{Address: "module.network.*", Groups: []string{"network"}},
+				{Address: "aws_*", Groups: []string{"ops"}},
+			},
+			want: map[string][]string{
+				"dba":     {"aws_db_instance.main"},
+				"network": {"module.network.aws_vpc.main"},
+				"ops":     {"aws_db_instance.main", "aws_s3_bucket.logs"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := RequiredGroups(tt.protected, testPlan)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("RequiredGroups() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
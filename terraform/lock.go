@@ -0,0 +1,82 @@
+package terraform
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// lockInfoRegexp matches the fields of the lock info printed on lock errors,
+// colored diagnostics lines start with a "│" gutter
+var lockInfoRegexp = regexp.MustCompile(`(?m)^[\s│]*(ID|Path|Operation|Who|Version|Created|Info):[ \t]*(.*)$`)
+
+// Lock describes the state lock held by another run
+type Lock struct {
+	ID, Path, Operation, Who, Version, Created, Info string
+}
+
+// lockCreatedLayout is the layout of the lock creation time
+const lockCreatedLayout = "2006-01-02 15:04:05.999999999 -0700 MST"
+
+// CreatedTime returns the lock creation time
+func (l Lock) CreatedTime() (time.Time, error) {
+	created, err := time.Parse(lockCreatedLayout, l.Created)
+	if err != nil {
+		return created, fmt.Errorf("failed to parse lock creation time %q: %w", l.Created, err)
+	}
+	return created, nil
+}
+
+// LockWho returns the lock holder identity recorded by terraform for locks
+// taken by this process, "user@hostname"
+func LockWho() string {
+	username := ""
+	if u, err := user.Current(); err == nil {
+		username = u.Username
+	}
+	host, _ := os.Hostname()
+	return fmt.Sprintf("%s@%s", username, host)
+}
+
+// ParseLock returns the lock info of a state lock error found in output
+func ParseLock(output string) (Lock, bool) {
+
+	lock := Lock{}
+
+	// Lock info follows the lock acquisition error
+	i := strings.Index(output, "Error acquiring the state lock")
+	if i < 0 {
+		return lock, false
+	}
+	j := strings.Index(output[i:], "Lock Info:")
+	if j < 0 {
+		return lock, false
+	}
+
+	for _, match := range lockInfoRegexp.FindAllStringSubmatch(output[i+j:], -1) {
+		value := strings.TrimSpace(match[2])
+		switch match[1] {
+		case "ID":
+			if lock.ID == "" {
+				lock.ID = value
+			}
+		case "Path":
+			lock.Path = value
+		case "Operation":
+			lock.Operation = value
+		case "Who":
+			lock.Who = value
+		case "Version":
+			lock.Version = value
+		case "Created":
+			lock.Created = value
+		case "Info":
+			lock.Info = value
+		}
+	}
+
+	return lock, lock.ID != ""
+}
@@ -0,0 +1,142 @@
+package terraform
+
+import (
+	"testing"
+	"time"
+)
+
+const lockErrorOutput = `
+Error: Error acquiring the state lock
+
+Error message: ConditionalCheckFailedException: The conditional request
+failed
+Lock Info:
+  ID:        2f0b3a1e-7c1d-8f5e-9a0b-6c2d3e4f5a6b
+  Path:      tfstate-bucket/env:/production/network.tfstate
+  Operation: OperationTypeApply
+  Who:       gitlab-runner@runner-abc123
+  Version:   1.3.7
+  Created:   2023-01-12 09:41:07.123456789 +0000 UTC
+  Info:
+
+Terraform acquires a state lock to protect the state from being written
+by multiple users at the same time. Please resolve the issue above and try
+again. For most commands, you can disable locking with the "-lock=false"
+flag, but this is not recommended.
+`
+
+const lockErrorColorOutput = `╷
+│ Error: Error acquiring the state lock
+│
+│ Error message: ConditionalCheckFailedException: The conditional request
+│ failed
+│ Lock Info:
+│   ID:        2f0b3a1e-7c1d-8f5e-9a0b-6c2d3e4f5a6b
+│   Path:      tfstate-bucket/env:/production/network.tfstate
+│   Operation: OperationTypeApply
+│   Who:       gitlab-runner@runner-abc123
+│   Version:   1.3.7
+│   Created:   2023-01-12 09:41:07.123456789 +0000 UTC
+│   Info:
+│
+│
+│ Terraform acquires a state lock to protect the state from being written
+│ by multiple users at the same time. Please resolve the issue above and try
+│ again. For most commands, you can disable locking with the "-lock=false"
+│ flag, but this is not recommended.
+╵
+`
+
+func TestParseLock(t *testing.T) {
+
+	lock := Lock{
+		ID:        "2f0b3a1e-7c1d-8f5e-9a0b-6c2d3e4f5a6b",
+		Path:      "tfstate-bucket/env:/production/network.tfstate",
+		Operation: "OperationTypeApply",
+		Who:       "gitlab-runner@runner-abc123",
+		Version:   "1.3.7",
+		Created:   "2023-01-12 09:41:07.123456789 +0000 UTC",
+	}
+
+	tests := []struct {
+		name   string
+		output string
+		want   Lock
+		ok     bool
+	}{
+		{
+			name:   "lock error",
+			output: lockErrorOutput,
+			want:   lock,
+			ok:     true,
+		},
+		{
+			name:   "colored lock error",
+			output: lockErrorColorOutput,
+			want:   lock,
+			ok:     true,
+		},
+		{
+			name:   "other error",
+			output: "Error: Invalid reference\n\n  on main.tf line 3:\n   3:   ID: foo\n",
+		},
+		{
+			name:   "lock error without lock info",
+			output: "Error: Error acquiring the state lock\n\nError message: timeout\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := ParseLock(tt.output)
+			if ok != tt.ok {
+				t.Fatalf("ParseLock() ok = %v, want %v", ok, tt.ok)
+			}
+			if got != tt.want {
+				t.Errorf("ParseLock() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLockCreatedTime(t *testing.T) {
+
+	tests := []struct {
+		created string
+		want    time.Time
+		wantErr bool
+	}{
+		{
+			created: "2023-01-12 09:41:07.123456789 +0000 UTC",
+			want:    time.Date(2023, 1, 12, 9, 41, 7, 123456789, time.UTC),
+		},
+		{
+			created: "2023-01-12 10:41:07.5 +0100 CET",
+			want:    time.Date(2023, 1, 12, 9, 41, 7, 500000000, time.UTC),
+		},
+		{
+			created: "2023-01-12 09:41:07 +0000 UTC",
+			want:    time.Date(2023, 1, 12, 9, 41, 7, 0, time.UTC),
+		},
+		{
+			created: "2023-01-12T09:41:07Z",
+			wantErr: true,
+		},
+		{
+			created: "",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.created, func(t *testing.T) {
+			got, err := Lock{Created: tt.created}.CreatedTime()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("CreatedTime() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !got.Equal(tt.want) {
+				t.Errorf("CreatedTime() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
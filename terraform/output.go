@@ -0,0 +1,49 @@
+package terraform
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/Ouest-France/gogci/command"
+)
+
+// Output is a root module output from "terraform output -json"
+type Output struct {
+	Sensitive bool            `json:"sensitive"`
+	Value     json.RawMessage `json:"value"`
+}
+
+// String returns strings as is and other values as compact JSON
+func (o Output) String() string {
+
+	var s string
+	if err := json.Unmarshal(o.Value, &s); err == nil {
+		return s
+	}
+
+	var compact bytes.Buffer
+	if err := json.Compact(&compact, o.Value); err != nil {
+		return string(o.Value)
+	}
+
+	return compact.String()
+}
+
+// ShowOutputs reads the root module outputs with "terraform output -json"
+func ShowOutputs(binary string) (map[string]Output, error) {
+
+	stdout, stderr, _, err := command.Output(binary, []string{"output", "-json"})
+	if err != nil {
+		return nil, fmt.Errorf("failed to show outputs: %s: %w", strings.TrimSpace(string(stderr)), err)
+	}
+
+	outputs := map[string]Output{}
+	err = json.Unmarshal(stdout, &outputs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode JSON outputs: %w", err)
+	}
+
+	return outputs, nil
+}
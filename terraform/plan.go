@@ -0,0 +1,100 @@
+package terraform
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/Ouest-France/gogci/command"
+)
+
+// Plan is the subset of the terraform JSON plan representation used by gogci
+type Plan struct {
+	FormatVersion   string           `json:"format_version"`
+	ResourceChanges []ResourceChange `json:"resource_changes"`
+}
+
+// ResourceChange describes the planned change of a resource instance
+type ResourceChange struct {
+	Address string `json:"address"`
+	Mode    string `json:"mode"`
+	Type    string `json:"type"`
+	Name    string `json:"name"`
+	Change  Change `json:"change"`
+}
+
+// Change holds the actions planned on a resource instance
+type Change struct {
+	Actions []string `json:"actions"`
+}
+
+// Deleted returns true when the resource is destroyed, replacements included
+func (rc ResourceChange) Deleted() bool {
+	for _, action := range rc.Change.Actions {
+		if action == "delete" {
+			return true
+		}
+	}
+	return false
+}
+
+// Changed returns true when the resource is created, updated or destroyed
+func (rc ResourceChange) Changed() bool {
+	for _, action := range rc.Change.Actions {
+		if action != "no-op" && action != "read" {
+			return true
+		}
+	}
+	return false
+}
+
+// ShowPlan reads a saved plan file with "terraform show -json"
+func ShowPlan(binary, planFile string) (*Plan, error) {
+
+	stdout, stderr, _, err := command.Output(binary, []string{"show", "-json", planFile})
+	if err != nil {
+		return nil, fmt.Errorf("failed to show plan file %q: %s: %w", planFile, strings.TrimSpace(string(stderr)), err)
+	}
+
+	plan := &Plan{}
+	err = json.Unmarshal(stdout, plan)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode JSON plan: %w", err)
+	}
+
+	return plan, nil
+}
+
+// PlanFileFromArgs returns the plan file written by "plan -out" arguments
+func PlanFileFromArgs(args []string) string {
+	for i, arg := range args {
+		if strings.HasPrefix(arg, "-out=") {
+			return strings.TrimPrefix(arg, "-out=")
+		}
+		if arg == "-out" && i+1 < len(args) {
+			return args[i+1]
+		}
+	}
+	return ""
+}
+
+// valueFlags are apply flags which may take their value as the next argument
+var valueFlags = map[string]bool{
+	"-var": true, "-var-file": true, "-target": true, "-replace": true,
+	"-parallelism": true, "-lock-timeout": true, "-state": true,
+	"-state-out": true, "-backup": true,
+}
+
+// PlanFileFromApplyArgs returns the saved plan file passed to "apply"
+func PlanFileFromApplyArgs(args []string) string {
+	for i := 0; i < len(args); i++ {
+		if valueFlags[args[i]] {
+			i++
+			continue
+		}
+		if !strings.HasPrefix(args[i], "-") {
+			return args[i]
+		}
+	}
+	return ""
+}
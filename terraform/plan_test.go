@@ -0,0 +1,78 @@
+package terraform
+
+import "testing"
+
+func TestPlanFileFromArgs(t *testing.T) {
+
+	tests := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{"no args", []string{}, ""},
+		{"no out", []string{"-lock-timeout=60s", "-var", "env=prod"}, ""},
+		{"out with equal sign", []string{"-input=false", "-out=plan.tfplan"}, "plan.tfplan"},
+		{"out as next arg", []string{"-out", "plan.tfplan", "-input=false"}, "plan.tfplan"},
+		{"out without value", []string{"-input=false", "-out"}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := PlanFileFromArgs(tt.args); got != tt.want {
+				t.Errorf("PlanFileFromArgs(%q) = %q, want %q", tt.args, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPlanFileFromApplyArgs(t *testing.T) {
+
+	tests := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{"no args", []string{}, ""},
+		{"flags only", []string{"-auto-approve", "-input=false", "-var=env=prod"}, ""},
+		{"plan file", []string{"plan.tfplan"}, "plan.tfplan"},
+		{"plan file after flags", []string{"-auto-approve", "-lock-timeout=60s", "plan.tfplan"}, "plan.tfplan"},
+		{"flag values are skipped", []string{"-var", "env=prod", "-var-file", "prod.tfvars", "-target", "aws_vpc.main", "-parallelism", "4", "plan.tfplan"}, "plan.tfplan"},
+		{"flag value without plan file", []string{"-var-file", "prod.tfvars"}, ""},
+		{"state flags values are skipped", []string{"-state", "a.tfstate", "-state-out", "b.tfstate", "-backup", "c.tfstate"}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := PlanFileFromApplyArgs(tt.args); got != tt.want {
+				t.Errorf("PlanFileFromApplyArgs(%q) = %q, want %q", tt.args, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResourceChangeActions(t *testing.T) {
+
+	tests := []struct {
+		actions []string
+		deleted bool
+		changed bool
+	}{
+		{[]string{"no-op"}, false, false},
+		{[]string{"read"}, false, false},
+		{[]string{"create"}, false, true},
+		{[]string{"update"}, false, true},
+		{[]string{"delete"}, true, true},
+		{[]string{"delete", "create"}, true, true},
+		{[]string{"create", "delete"}, true, true},
+	}
+
+	for _, tt := range tests {
+		rc := ResourceChange{Change: Change{Actions: tt.actions}}
+		if got := rc.Deleted(); got != tt.deleted {
+			t.Errorf("Deleted() of %q = %v, want %v", tt.actions, got, tt.deleted)
+		}
+		if got := rc.Changed(); got != tt.changed {
+			t.Errorf("Changed() of %q = %v, want %v", tt.actions, got, tt.changed)
+		}
+	}
+}
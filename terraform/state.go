@@ -0,0 +1,61 @@
+package terraform
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Ouest-France/gogci/command"
+)
+
+// StateBackup is a state snapshot saved to a file
+type StateBackup struct {
+	File, SHA256 string
+}
+
+// BackupState pulls the current state and writes it to file in dir
+func BackupState(tool Tool, dir, file string) (StateBackup, error) {
+
+	stdout, stderr, _, err := command.Output(tool.Binary(), tool.Args([]string{"state", "pull"}))
+	if err != nil {
+		return StateBackup{}, fmt.Errorf("failed to pull state: %s: %w", strings.TrimSpace(string(stderr)), err)
+	}
+
+	err = os.MkdirAll(dir, 0700)
+	if err != nil {
+		return StateBackup{}, fmt.Errorf("failed to create state backup dir: %w", err)
+	}
+
+	path := filepath.Join(dir, file)
+	err = ioutil.WriteFile(path, stdout, 0600)
+	if err != nil {
+		return StateBackup{}, fmt.Errorf("failed to write state backup: %w", err)
+	}
+
+	return StateBackup{File: path, SHA256: fmt.Sprintf("%x", sha256.Sum256(stdout))}, nil
+}
+
+// StateChecksum returns the SHA256 checksum of a state snapshot file
+func StateChecksum(file string) (string, error) {
+
+	content, err := ioutil.ReadFile(file)
+	if err != nil {
+		return "", fmt.Errorf("failed to read state snapshot: %w", err)
+	}
+
+	return fmt.Sprintf("%x", sha256.Sum256(content)), nil
+}
+
+// RestoreState pushes a state snapshot, overriding lineage and serial checks
+func RestoreState(tool Tool, file string) error {
+
+	_, stderr, _, err := command.Run(tool.Binary(), tool.Args([]string{"state", "push", "-force", file}))
+	if err != nil {
+		return fmt.Errorf("failed to push state: %s: %w", strings.TrimSpace(string(stderr)), err)
+	}
+
+	return nil
+}
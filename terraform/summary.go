@@ -0,0 +1,141 @@
+package terraform
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var (
+	planNoChangesRegexp = regexp.MustCompile(`(?m)^No changes\. [^\n]*`)
+	planSummaryRegexp   = regexp.MustCompile(`([0-9]+) to add, ([0-9]+) to change, ([0-9]+) to destroy`)
+	applySummaryRegexp  = regexp.MustCompile(`([0-9]+) added, ([0-9]+) changed, ([0-9]+) destroyed`)
+
+	// Lines starting the planned actions, terraform and OpenTofu banners included
+	planStartRegexp = regexp.MustCompile(`^((Terraform|OpenTofu) will perform the following actions|Changes to Outputs:)`)
+
+	// Lines following the planned actions
+	planEndRegexp = regexp.MustCompile(`^(─|Saved the plan to:|Note: You didn't use the -out option|Releasing state lock)`)
+)
+
+// Unit is the output of a single terraform root module
+type Unit struct {
+	Name   string
+	Output string
+}
+
+// PlanSummary holds resource counts parsed from a terraform plan output
+type PlanSummary struct {
+	Found                bool
+	NoChanges            bool
+	Add, Change, Destroy int
+	Text                 string
+}
+
+// ParsePlanSummary extracts the summary of a terraform plan output
+func ParsePlanSummary(output string) PlanSummary {
+
+	if text := planNoChangesRegexp.FindString(output); text != "" {
+		return PlanSummary{Found: true, NoChanges: true, Text: strings.TrimSpace(text)}
+	}
+
+	match := planSummaryRegexp.FindStringSubmatch(output)
+	if match == nil {
+		return PlanSummary{}
+	}
+
+	summary := PlanSummary{Found: true, Text: match[0]}
+	summary.Add, _ = strconv.Atoi(match[1])
+	summary.Change, _ = strconv.Atoi(match[2])
+	summary.Destroy, _ = strconv.Atoi(match[3])
+
+	return summary
+}
+
+// TotalPlanSummary adds up plan summaries of all units
+func TotalPlanSummary(units []Unit) PlanSummary {
+
+	if len(units) == 1 {
+		return ParsePlanSummary(units[0].Output)
+	}
+
+	total := PlanSummary{Found: len(units) > 0, NoChanges: true}
+	for _, unit := range units {
+		summary := ParsePlanSummary(unit.Output)
+		total.Found = total.Found && summary.Found
+		total.NoChanges = total.NoChanges && summary.NoChanges
+		total.Add += summary.Add
+		total.Change += summary.Change
+		total.Destroy += summary.Destroy
+	}
+
+	total.Text = fmt.Sprintf("%d to add, %d to change, %d to destroy", total.Add, total.Change, total.Destroy)
+	if total.NoChanges {
+		total.Text = "No changes."
+	}
+
+	return total
+}
+
+// ParseApplySummary extracts the summary of a terraform apply output
+func ParseApplySummary(output string) string {
+	return applySummaryRegexp.FindString(output)
+}
+
+// Fingerprint returns a hash of the actions part of a terraform or OpenTofu
+// plan output, the whole output is hashed when no actions are found
+func Fingerprint(output string) string {
+
+	// Keep only lines describing planned actions
+	lines := []string{}
+	started := false
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimRight(line, " \t\r")
+
+		if !started && strings.HasPrefix(line, "No changes.") {
+			lines = []string{"No changes."}
+			started = true
+			break
+		}
+		if !started && planStartRegexp.MatchString(line) {
+			// The tool banner is left out, both tools plan the same actions
+			started = true
+			if strings.HasPrefix(line, "Changes to Outputs:") {
+				lines = append(lines, line)
+			}
+			continue
+		}
+		if started && planEndRegexp.MatchString(line) {
+			break
+		}
+		if started {
+			lines = append(lines, line)
+		}
+	}
+
+	// Unknown output format can't be compared
+	if !started {
+		return fmt.Sprintf("%x", sha256.Sum256([]byte(output)))
+	}
+
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(strings.Join(lines, "\n"))))
+}
+
+// PlanFingerprint returns a hash of the planned actions of all units
+func PlanFingerprint(units []Unit) string {
+
+	if len(units) == 1 {
+		return Fingerprint(units[0].Output)
+	}
+
+	fingerprints := []string{}
+	for _, unit := range units {
+		fingerprints = append(fingerprints, unit.Name+"="+Fingerprint(unit.Output))
+	}
+	sort.Strings(fingerprints)
+
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(strings.Join(fingerprints, "\n"))))
+}
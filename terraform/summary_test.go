@@ -0,0 +1,284 @@
+package terraform
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+const terraformPlanOutput = `random_pet.name: Refreshing state... [id=sensible-gnu]
+
+Terraform used the selected providers to generate the following execution
+plan. Resource actions are indicated with the following symbols:
+  + create
+  ~ update in-place
+
+Terraform will perform the following actions:
+
+  # local_file.config will be created
+  + resource "local_file" "config" {
+      + content              = "No changes. Everything is fine."
+      + directory_permission = "0777"
+      + file_permission      = "0777"
+      + filename             = "./config.txt"
+      + id                   = (known after apply)
+    }
+
+  # random_pet.name will be updated in-place
+  ~ resource "random_pet" "name" {
+        id     = "sensible-gnu"
+      ~ length = 2 -> 3
+    }
+
+Plan: 1 to add, 1 to change, 0 to destroy.
+
+Changes to Outputs:
+  + filename = "./config.txt"
+
+─────────────────────────────────────────────────────────────────────────────
+
+Note: You didn't use the -out option to save this plan, so Terraform can't
+guarantee to take exactly these actions if you run "terraform apply" now.
+`
+
+const tofuPlanOutput = `random_pet.name: Refreshing state... [id=sensible-gnu]
+
+OpenTofu used the selected providers to generate the following execution
+plan. Resource actions are indicated with the following symbols:
+  + create
+  ~ update in-place
+
+OpenTofu will perform the following actions:
+
+  # local_file.config will be created
+  + resource "local_file" "config" {
+      + content              = "No changes. Everything is fine."
+      + directory_permission = "0777"
+      + file_permission      = "0777"
+      + filename             = "./config.txt"
+      + id                   = (known after apply)
+    }
+
+  # random_pet.name will be updated in-place
+  ~ resource "random_pet" "name" {
+        id     = "sensible-gnu"
+      ~ length = 2 -> 3
+    }
+
+Plan: 1 to add, 1 to change, 0 to destroy.
+
+Changes to Outputs:
+  + filename = "./config.txt"
+
+─────────────────────────────────────────────────────────────────────────────
+
+Saved the plan to: plan.tfplan
+
+To perform exactly these actions, run the following command to apply:
+    tofu apply "plan.tfplan"
+`
+
+const terraformNoChangesOutput = `random_pet.name: Refreshing state... [id=sensible-gnu]
+
+No changes. Your infrastructure matches the configuration.
+
+Terraform has compared your real infrastructure against your configuration
+and found no differences, so no changes are needed.
+`
+
+const tofuNoChangesOutput = `random_pet.name: Refreshing state... [id=sensible-gnu]
+
+No changes. Your infrastructure matches the configuration.
+
+OpenTofu has compared your real infrastructure against your configuration
+and found no differences, so no changes are needed.
+`
+
+const terraformOutputsOnlyOutput = `random_pet.name: Refreshing state... [id=sensible-gnu]
+
+Changes to Outputs:
+  + name = "sensible-gnu"
+
+You can apply this plan to save these new output values to the Terraform
+state, without changing any real infrastructure.
+
+─────────────────────────────────────────────────────────────────────────────
+`
+
+func TestFingerprint(t *testing.T) {
+
+	empty := fmt.Sprintf("%x", sha256.Sum256(nil))
+
+	tests := []struct {
+		name    string
+		a, b    string
+		same    bool
+		noEmpty bool
+	}{
+		{
+			name:    "terraform and tofu plan the same actions",
+			a:       terraformPlanOutput,
+			b:       tofuPlanOutput,
+			same:    true,
+			noEmpty: true,
+		},
+		{
+			name:    "refresh lines are ignored",
+			a:       terraformPlanOutput,
+			b:       strings.Replace(terraformPlanOutput, "[id=sensible-gnu]\n", "[id=sensible-gnu]\ndata.local_file.x: Reading...\n", 1),
+			same:    true,
+			noEmpty: true,
+		},
+		{
+			name:    "changed terraform attribute",
+			a:       terraformPlanOutput,
+			b:       strings.Replace(terraformPlanOutput, "2 -> 3", "2 -> 4", 1),
+			noEmpty: true,
+		},
+		{
+			name:    "changed tofu attribute",
+			a:       tofuPlanOutput,
+			b:       strings.Replace(tofuPlanOutput, "2 -> 3", "2 -> 4", 1),
+			noEmpty: true,
+		},
+		{
+			name:    "changed output",
+			a:       terraformPlanOutput,
+			b:       strings.Replace(terraformPlanOutput, `+ filename = "./config.txt"`, `+ filename = "./other.txt"`, 1),
+			noEmpty: true,
+		},
+		{
+			name:    "attribute value containing no changes",
+			a:       terraformPlanOutput,
+			b:       terraformNoChangesOutput,
+			noEmpty: true,
+		},
+		{
+			name:    "terraform and tofu without changes",
+			a:       terraformNoChangesOutput,
+			b:       tofuNoChangesOutput,
+			same:    true,
+			noEmpty: true,
+		},
+		{
+			name:    "outputs only plan",
+			a:       terraformOutputsOnlyOutput,
+			b:       strings.Replace(terraformOutputsOnlyOutput, `"sensible-gnu"`, `"other-gnu"`, 1),
+			noEmpty: true,
+		},
+		{
+			name: "unknown output is hashed whole",
+			a:    "Error: something failed",
+			b:    "Error: something else failed",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, b := Fingerprint(tt.a), Fingerprint(tt.b)
+			if (a == b) != tt.same {
+				t.Errorf("fingerprints equal = %v, want %v", a == b, tt.same)
+			}
+			if tt.noEmpty && (a == empty || b == empty) {
+				t.Errorf("fingerprint of empty actions")
+			}
+		})
+	}
+}
+
+func TestParsePlanSummary(t *testing.T) {
+
+	tests := []struct {
+		name   string
+		output string
+		want   PlanSummary
+	}{
+		{
+			name:   "terraform plan",
+			output: terraformPlanOutput,
+			want:   PlanSummary{Found: true, Add: 1, Change: 1, Text: "1 to add, 1 to change, 0 to destroy"},
+		},
+		{
+			name:   "tofu plan",
+			output: tofuPlanOutput,
+			want:   PlanSummary{Found: true, Add: 1, Change: 1, Text: "1 to add, 1 to change, 0 to destroy"},
+		},
+		{
+			name:   "terraform no changes",
+			output: terraformNoChangesOutput,
+			want:   PlanSummary{Found: true, NoChanges: true, Text: "No changes. Your infrastructure matches the configuration."},
+		},
+		{
+			name:   "tofu no changes",
+			output: tofuNoChangesOutput,
+			want:   PlanSummary{Found: true, NoChanges: true, Text: "No changes. Your infrastructure matches the configuration."},
+		},
+		{
+			name:   "destroy plan",
+			output: "Plan: 0 to add, 0 to change, 12 to destroy.\n",
+			want:   PlanSummary{Found: true, Destroy: 12, Text: "0 to add, 0 to change, 12 to destroy"},
+		},
+		{
+			name:   "failed plan",
+			output: "Error: Invalid reference\n",
+			want:   PlanSummary{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ParsePlanSummary(tt.output); got != tt.want {
+				t.Errorf("ParsePlanSummary() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTotalPlanSummary(t *testing.T) {
+
+	tests := []struct {
+		name  string
+		units []Unit
+		want  PlanSummary
+	}{
+		{
+			name:  "single unit",
+			units: []Unit{{Name: "app", Output: terraformNoChangesOutput}},
+			want:  PlanSummary{Found: true, NoChanges: true, Text: "No changes. Your infrastructure matches the configuration."},
+		},
+		{
+			name: "units added up",
+			units: []Unit{
+				{Name: "app", Output: terraformPlanOutput},
+				{Name: "db", Output: "Plan: 2 to add, 0 to change, 3 to destroy.\n"},
+				{Name: "dns", Output: tofuNoChangesOutput},
+			},
+			want: PlanSummary{Found: true, Add: 3, Change: 1, Destroy: 3, Text: "3 to add, 1 to change, 3 to destroy"},
+		},
+		{
+			name: "units without changes",
+			units: []Unit{
+				{Name: "app", Output: terraformNoChangesOutput},
+				{Name: "dns", Output: tofuNoChangesOutput},
+			},
+			want: PlanSummary{Found: true, NoChanges: true, Text: "No changes."},
+		},
+		{
+			name: "failed unit",
+			units: []Unit{
+				{Name: "app", Output: terraformPlanOutput},
+				{Name: "db", Output: "Error: Invalid reference\n"},
+			},
+			want: PlanSummary{Add: 1, Change: 1, Text: "1 to add, 1 to change, 0 to destroy"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := TotalPlanSummary(tt.units); got != tt.want {
+				t.Errorf("TotalPlanSummary() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
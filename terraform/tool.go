@@ -0,0 +1,189 @@
+package terraform
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/Ouest-France/gogci/command"
+)
+
+// Tool is an infrastructure as code tool driven by gogci
+type Tool interface {
+	// Name of the tool as displayed in notifications
+	Name() string
+
+	// Binary executed to run the tool
+	Binary() string
+
+	// Version of the tool binary
+	Version() (string, error)
+
+	// Args returns the tool arguments of a terraform command
+	Args(args []string) []string
+
+	// Units splits the tool output by root module
+	Units(output string) []Unit
+}
+
+// NewTool returns the tool matching name, binary defaults to the tool name
+func NewTool(name, binary string) (Tool, error) {
+	switch name {
+	case "", "terraform":
+		return &terraformTool{name: "terraform", binary: defaultBinary(binary, "terraform")}, nil
+	case "tofu", "opentofu":
+		return &terraformTool{name: "opentofu", binary: defaultBinary(binary, "tofu")}, nil
+	case "terragrunt":
+		return &terragruntTool{binary: defaultBinary(binary, "terragrunt")}, nil
+	default:
+		return nil, fmt.Errorf("unknown tool %q, must be one of terraform, tofu or terragrunt", name)
+	}
+}
+
+// Describe returns the tool name followed by its version when available
+func Describe(tool Tool) string {
+	version, err := tool.Version()
+	if err != nil || version == "" {
+		return tool.Name()
+	}
+	return fmt.Sprintf("%s %s", tool.Name(), version)
+}
+
+func defaultBinary(binary, fallback string) string {
+	if binary == "" {
+		return fallback
+	}
+	return binary
+}
+
+// terraformTool runs terraform or a compatible binary such as OpenTofu
+type terraformTool struct {
+	name, binary, version string
+}
+
+func (t *terraformTool) Name() string {
+	return t.name
+}
+
+func (t *terraformTool) Binary() string {
+	return t.binary
+}
+
+func (t *terraformTool) Version() (string, error) {
+
+	if t.version != "" {
+		return t.version, nil
+	}
+
+	// Get version as JSON
+	stdout, _, _, err := command.Output(t.binary, []string{"version", "-json"})
+	if err != nil {
+		return "", fmt.Errorf("failed to get %s version: %w", t.name, err)
+	}
+
+	version := struct {
+		Version string `json:"terraform_version"`
+	}{}
+	err = json.Unmarshal(stdout, &version)
+	if err != nil {
+		return "", fmt.Errorf("failed to decode %s version: %w", t.name, err)
+	}
+	t.version = version.Version
+
+	return t.version, nil
+}
+
+func (t *terraformTool) Args(args []string) []string {
+	return args
+}
+
+func (t *terraformTool) Units(output string) []Unit {
+	return []Unit{{Name: ".", Output: output}}
+}
+
+// terragruntTool runs terragrunt on all units below the working directory
+type terragruntTool struct {
+	binary, version string
+}
+
+// terragruntVersionRegexp matches the output of "terragrunt --version"
+var terragruntVersionRegexp = regexp.MustCompile(`v?([0-9]+\.[0-9]+\.[0-9]+\S*)`)
+
+// terragruntPrefixRegexp matches lines prefixed with the unit path
+var terragruntPrefixRegexp = regexp.MustCompile(`^\[([^\]]+)\] ?(.*)$`)
+
+func (t *terragruntTool) Name() string {
+	return "terragrunt"
+}
+
+func (t *terragruntTool) Binary() string {
+	return t.binary
+}
+
+func (t *terragruntTool) Version() (string, error) {
+
+	if t.version != "" {
+		return t.version, nil
+	}
+
+	stdout, _, _, err := command.Output(t.binary, []string{"--version"})
+	if err != nil {
+		return "", fmt.Errorf("failed to get terragrunt version: %w", err)
+	}
+
+	match := terragruntVersionRegexp.FindStringSubmatch(string(stdout))
+	if match == nil {
+		return "", fmt.Errorf("failed to parse terragrunt version %q", strings.TrimSpace(string(stdout)))
+	}
+	t.version = match[1]
+
+	return t.version, nil
+}
+
+func (t *terragruntTool) Args(args []string) []string {
+
+	if len(args) == 0 {
+		return args
+	}
+
+	// Run init, plan and apply on all units with prefixed output
+	switch args[0] {
+	case "init", "plan", "apply":
+		return append([]string{"run-all", args[0], "--terragrunt-non-interactive", "--terragrunt-include-module-prefix"}, args[1:]...)
+	default:
+		return args
+	}
+}
+
+func (t *terragruntTool) Units(output string) []Unit {
+
+	// Group lines by unit prefix
+	lines := map[string][]string{}
+	for _, line := range strings.Split(output, "\n") {
+		match := terragruntPrefixRegexp.FindStringSubmatch(line)
+		if match == nil {
+			continue
+		}
+		lines[match[1]] = append(lines[match[1]], match[2])
+	}
+
+	// Without prefixed lines the output belongs to a single unit
+	if len(lines) == 0 {
+		return []Unit{{Name: ".", Output: output}}
+	}
+
+	names := []string{}
+	for name := range lines {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	units := []Unit{}
+	for _, name := range names {
+		units = append(units, Unit{Name: name, Output: strings.Join(lines[name], "\n")})
+	}
+
+	return units
+}
@@ -0,0 +1,308 @@
+package terraform
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+
+	version "github.com/hashicorp/go-version"
+)
+
+// requiredVersionRegexp matches the required_version setting of a terraform block
+var requiredVersionRegexp = regexp.MustCompile(`required_version\s*=\s*"([^"]+)"`)
+
+// RequiredVersion returns the version constraint of the root module in dir,
+// read from a ".terraform-version" file or the "required_version" setting
+func RequiredVersion(dir string) (string, error) {
+
+	// Read version file first
+	content, err := ioutil.ReadFile(filepath.Join(dir, ".terraform-version"))
+	if err != nil && !os.IsNotExist(err) {
+		return "", fmt.Errorf("failed to read .terraform-version file: %w", err)
+	}
+	if v := strings.TrimSpace(string(content)); v != "" && !strings.HasPrefix(v, "latest") {
+		return v, nil
+	}
+
+	// Search required_version in terraform files
+	files, err := filepath.Glob(filepath.Join(dir, "*.tf"))
+	if err != nil {
+		return "", fmt.Errorf("failed to list terraform files: %w", err)
+	}
+	sort.Strings(files)
+
+	constraints := []string{}
+	for _, file := range files {
+		content, err := ioutil.ReadFile(file)
+		if err != nil {
+			return "", fmt.Errorf("failed to read terraform file %q: %w", file, err)
+		}
+
+		for _, match := range requiredVersionRegexp.FindAllStringSubmatch(string(content), -1) {
+			constraints = append(constraints, match[1])
+		}
+	}
+
+	return strings.Join(constraints, ", "), nil
+}
+
+// PinVersion returns a tool running a binary that satisfies the version
+// required by the root module in dir. The binary is searched in versionsDir
+// as "<version>/<binary>" and installed from mirror when missing. Without
+// versionsDir, the current binary version is checked against the constraint.
+func PinVersion(tool Tool, dir, versionsDir, mirror string) (Tool, error) {
+
+	// Only terraform compatible tools can be pinned
+	t, ok := tool.(*terraformTool)
+	if !ok {
+		return tool, nil
+	}
+
+	// Get root module constraint
+	required, err := RequiredVersion(dir)
+	if err != nil {
+		return nil, err
+	}
+	if required == "" {
+		return tool, nil
+	}
+	constraint, err := version.NewConstraint(required)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse required version %q: %w", required, err)
+	}
+
+	// Check current binary when no versions dir is set
+	if versionsDir == "" {
+		current, err := t.Version()
+		if err != nil {
+			return nil, err
+		}
+		v, err := version.NewVersion(current)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s version %q: %w", t.name, current, err)
+		}
+		if !constraint.Check(v) {
+			return nil, fmt.Errorf("%s %s does not satisfy required version %q", t.name, current, required)
+		}
+		return tool, nil
+	}
+
+	// Search a matching installed version
+	base := filepath.Base(t.binary)
+	installed, err := installedVersions(versionsDir, base)
+	if err != nil {
+		return nil, err
+	}
+	if v := latestMatching(installed, constraint); v != nil {
+		return &terraformTool{name: t.name, binary: filepath.Join(versionsDir, v.Original(), base), version: v.String()}, nil
+	}
+
+	if mirror == "" {
+		return nil, fmt.Errorf("no %s version satisfying %q found in %q", t.name, required, versionsDir)
+	}
+
+	// Install a matching version from mirror
+	available, err := mirrorVersions(mirror)
+	if err != nil {
+		return nil, err
+	}
+	v := latestMatching(available, constraint)
+	if v == nil {
+		return nil, fmt.Errorf("no %s version satisfying %q found in mirror %q", t.name, required, mirror)
+	}
+
+	binary, err := install(mirror, versionsDir, base, v.Original())
+	if err != nil {
+		return nil, fmt.Errorf("failed to install %s %s: %w", t.name, v, err)
+	}
+
+	return &terraformTool{name: t.name, binary: binary, version: v.String()}, nil
+}
+
+// installedVersions lists versions having a binary in versionsDir
+func installedVersions(versionsDir, binary string) ([]*version.Version, error) {
+
+	entries, err := ioutil.ReadDir(versionsDir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read versions dir %q: %w", versionsDir, err)
+	}
+
+	versions := []*version.Version{}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(versionsDir, entry.Name(), binary)); err != nil {
+			continue
+		}
+		if v, err := version.NewVersion(entry.Name()); err == nil {
+			versions = append(versions, v)
+		}
+	}
+
+	return versions, nil
+}
+
+// latestMatching returns the highest version satisfying constraint
+func latestMatching(versions []*version.Version, constraint version.Constraints) *version.Version {
+
+	var latest *version.Version
+	for _, v := range versions {
+		if v.Prerelease() != "" || !constraint.Check(v) {
+			continue
+		}
+		if latest == nil || v.GreaterThan(latest) {
+			latest = v
+		}
+	}
+
+	return latest
+}
+
+// mirrorVersions lists versions from the mirror "index.json", which follows
+// the releases.hashicorp.com layout
+func mirrorVersions(mirror string) ([]*version.Version, error) {
+
+	content, err := download(strings.TrimSuffix(mirror, "/") + "/index.json")
+	if err != nil {
+		return nil, err
+	}
+
+	index := struct {
+		Versions map[string]interface{} `json:"versions"`
+	}{}
+	err = json.Unmarshal(content, &index)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode mirror index: %w", err)
+	}
+
+	versions := []*version.Version{}
+	for name := range index.Versions {
+		if v, err := version.NewVersion(name); err == nil {
+			versions = append(versions, v)
+		}
+	}
+
+	return versions, nil
+}
+
+// install downloads a release archive from mirror, verifies its checksum
+// and extracts the binary to "<versionsDir>/<version>/<binary>"
+func install(mirror, versionsDir, binary, v string) (string, error) {
+
+	base := fmt.Sprintf("%s/%s", strings.TrimSuffix(mirror, "/"), v)
+	archiveName := fmt.Sprintf("%s_%s_%s_%s.zip", binary, v, runtime.GOOS, runtime.GOARCH)
+
+	// Download checksums and archive
+	sums, err := download(fmt.Sprintf("%s/%s_%s_SHA256SUMS", base, binary, v))
+	if err != nil {
+		return "", err
+	}
+	archive, err := download(fmt.Sprintf("%s/%s", base, archiveName))
+	if err != nil {
+		return "", err
+	}
+
+	// Verify archive checksum
+	expected := ""
+	scanner := bufio.NewScanner(bytes.NewReader(sums))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && fields[1] == archiveName {
+			expected = fields[0]
+		}
+	}
+	if expected == "" {
+		return "", fmt.Errorf("no checksum found for %s", archiveName)
+	}
+	if actual := fmt.Sprintf("%x", sha256.Sum256(archive)); actual != expected {
+		return "", fmt.Errorf("checksum mismatch for %s: expected %s, got %s", archiveName, expected, actual)
+	}
+
+	// Extract binary
+	reader, err := zip.NewReader(bytes.NewReader(archive), int64(len(archive)))
+	if err != nil {
+		return "", fmt.Errorf("failed to open archive %s: %w", archiveName, err)
+	}
+
+	for _, file := range reader.File {
+		if file.Name != binary {
+			continue
+		}
+
+		err = os.MkdirAll(filepath.Join(versionsDir, v), 0755)
+		if err != nil {
+			return "", fmt.Errorf("failed to create version dir: %w", err)
+		}
+
+		src, err := file.Open()
+		if err != nil {
+			return "", fmt.Errorf("failed to extract %s: %w", binary, err)
+		}
+		defer src.Close()
+
+		// Write to a temporary file renamed once complete, an interrupted
+		// install must not leave a binary listed as installed
+		path := filepath.Join(versionsDir, v, binary)
+		dst, err := ioutil.TempFile(filepath.Dir(path), binary+".*")
+		if err != nil {
+			return "", fmt.Errorf("failed to create %s: %w", path, err)
+		}
+		defer os.Remove(dst.Name())
+
+		// Zip reader checks the extracted file CRC-32 at end of file
+		_, err = io.Copy(dst, src)
+		if errClose := dst.Close(); err == nil {
+			err = errClose
+		}
+		if err == nil {
+			err = os.Chmod(dst.Name(), 0755)
+		}
+		if err == nil {
+			err = os.Rename(dst.Name(), path)
+		}
+		if err != nil {
+			return "", fmt.Errorf("failed to write %s: %w", path, err)
+		}
+
+		return path, nil
+	}
+
+	return "", fmt.Errorf("binary %s not found in archive %s", binary, archiveName)
+}
+
+// download returns the content at url
+func download(url string) ([]byte, error) {
+
+	resp, err := http.Get(url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download %s: %w", url, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to download %s: %s", url, resp.Status)
+	}
+
+	content, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", url, err)
+	}
+
+	return content, nil
+}
@@ -0,0 +1,217 @@
+package terraform
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	version "github.com/hashicorp/go-version"
+)
+
+func TestRequiredVersion(t *testing.T) {
+
+	tests := []struct {
+		name  string
+		files map[string]string
+		want  string
+	}{
+		{
+			name: "no constraint",
+			files: map[string]string{
+				"main.tf": `resource "random_pet" "name" {}`,
+			},
+			want: "",
+		},
+		{
+			name: "required version",
+			files: map[string]string{
+				"versions.tf": "terraform {\n  required_version = \">= 1.1, < 2.0\"\n}\n",
+			},
+			want: ">= 1.1, < 2.0",
+		},
+		{
+			name: "required versions of several files",
+			files: map[string]string{
+				"b.tf": "terraform {\n  required_version=\"< 2.0\"\n}\n",
+				"a.tf": "terraform {\n  required_version = \">= 1.1\"\n}\n",
+			},
+			want: ">= 1.1, < 2.0",
+		},
+		{
+			name: "version file first",
+			files: map[string]string{
+				".terraform-version": "1.3.7\n",
+				"versions.tf":        "terraform {\n  required_version = \">= 1.1\"\n}\n",
+			},
+			want: "1.3.7",
+		},
+		{
+			name: "latest version file ignored",
+			files: map[string]string{
+				".terraform-version": "latest:^1.3\n",
+				"versions.tf":        "terraform {\n  required_version = \">= 1.1\"\n}\n",
+			},
+			want: ">= 1.1",
+		},
+		{
+			name: "modules not searched",
+			files: map[string]string{
+				"modules/db/versions.tf": "terraform {\n  required_version = \">= 0.13\"\n}\n",
+			},
+			want: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "version")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+
+			for name, content := range tt.files {
+				path := filepath.Join(dir, name)
+				err := os.MkdirAll(filepath.Dir(path), 0755)
+				if err != nil {
+					t.Fatal(err)
+				}
+				err = ioutil.WriteFile(path, []byte(content), 0644)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			got, err := RequiredVersion(dir)
+			if err != nil {
+				t.Fatalf("RequiredVersion() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("RequiredVersion() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLatestMatching(t *testing.T) {
+
+	versions := []*version.Version{}
+	for _, v := range []string{"0.15.5", "1.2.9", "1.3.7", "1.4.0-rc1", "1.3.10"} {
+		versions = append(versions, version.Must(version.NewVersion(v)))
+	}
+
+	tests := []struct {
+		constraint string
+		want       string
+	}{
+		{">= 1.1, < 2.0", "1.3.10"},
+		{"~> 1.2.0", "1.2.9"},
+		{"1.3.7", "1.3.7"},
+		{">= 1.4", ""},
+		{"< 1.0", "0.15.5"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.constraint, func(t *testing.T) {
+			constraint, err := version.NewConstraint(tt.constraint)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got := ""
+			if v := latestMatching(versions, constraint); v != nil {
+				got = v.String()
+			}
+			if got != tt.want {
+				t.Errorf("latestMatching(%q) = %q, want %q", tt.constraint, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInstall(t *testing.T) {
+
+	// Release archive holding the terraform binary
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+	w, err := archive.Create("terraform")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fmt.Fprint(w, "#!/bin/sh\n")
+	if err := archive.Close(); err != nil {
+		t.Fatal(err)
+	}
+	archiveName := fmt.Sprintf("terraform_1.2.3_%s_%s.zip", runtime.GOOS, runtime.GOARCH)
+
+	tests := []struct {
+		name    string
+		sum     string
+		wantErr bool
+	}{
+		{
+			name: "valid checksum",
+			sum:  fmt.Sprintf("%x", sha256.Sum256(buf.Bytes())),
+		},
+		{
+			name:    "checksum mismatch",
+			sum:     fmt.Sprintf("%x", sha256.Sum256([]byte("other"))),
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch r.URL.Path {
+				case "/1.2.3/terraform_1.2.3_SHA256SUMS":
+					fmt.Fprintf(w, "%s  %s\n", tt.sum, archiveName)
+				case "/1.2.3/" + archiveName:
+					w.Write(buf.Bytes())
+				default:
+					http.NotFound(w, r)
+				}
+			}))
+			defer server.Close()
+
+			dir, err := ioutil.TempDir("", "versions")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+
+			path, err := install(server.URL, dir, "terraform", "1.2.3")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("install() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			// Only the complete binary is left in the version dir
+			want := []string{}
+			if !tt.wantErr {
+				want = []string{"terraform"}
+				if path != filepath.Join(dir, "1.2.3", "terraform") {
+					t.Errorf("install() = %q, want binary in version dir", path)
+				}
+				if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0755 {
+					t.Errorf("install() binary mode = %v, %v, want 0755", info, err)
+				}
+			}
+			entries, _ := ioutil.ReadDir(filepath.Join(dir, "1.2.3"))
+			got := []string{}
+			for _, entry := range entries {
+				got = append(got, entry.Name())
+			}
+			if fmt.Sprint(got) != fmt.Sprint(want) {
+				t.Errorf("install() version dir = %v, want %v", got, want)
+			}
+		})
+	}
+}